@@ -1,25 +1,78 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"go1f/pkg/config"
 	"go1f/pkg/db"
 	"go1f/pkg/server"
 	"log"
+	"os"
+	_ "time/tzdata" // часовые пояса TODO_TZ и X-Timezone без системной базы
 )
 
+const dbFile = "scheduler.db"
+
 func main() {
+	// go run . migrate list|up — работа с миграциями без запуска сервера
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := migrate(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	cfg, err := config.Load()
 	if err != nil {
 		log.Fatalf("Ошибка конфигурации: %v", err)
 	}
 
 	log.Println("Инициализация базы данных...")
-	store, err := db.NewStore("scheduler.db")
+	store, err := db.NewStore(dbFile)
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer store.Close()
+	store.SetQueryTimeout(cfg.QueryTimeout)
 
 	log.Println("Запуск сервера...")
 	server.StartServer(store, cfg)
 }
+
+// migrate выполняет команду migrate: list выводит миграции с отметкой
+// о применении, up применяет ожидающие
+func migrate(args []string) error {
+	if len(args) != 1 || (args[0] != "list" && args[0] != "up") {
+		return fmt.Errorf("использование: %s migrate list|up", os.Args[0])
+	}
+
+	store, err := db.Open(dbFile)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if args[0] == "up" {
+		applied, err := store.Migrate(context.Background())
+		for _, m := range applied {
+			fmt.Printf("применена %04d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("схема уже последней версии")
+		}
+		return err
+	}
+
+	statuses, err := store.Migrations(context.Background())
+	if err != nil {
+		return err
+	}
+	for _, m := range statuses {
+		state := "ожидает"
+		if m.Applied {
+			state = "применена"
+		}
+		fmt.Printf("%04d_%s\t%s\n", m.Version, m.Name, state)
+	}
+	return nil
+}
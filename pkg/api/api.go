@@ -1,17 +1,25 @@
 package api
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"go1f/pkg/config"
 	"go1f/pkg/dateutil"
 	"go1f/pkg/db"
+	"go1f/pkg/taskql"
+	"html"
+	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -19,14 +27,18 @@ import (
 const (
 	DateFormat      = "20060102"
 	DefaultPageSize = 50
+	MaxOccurrences  = 1000
+	MaxCalendarSize = 5 << 20
+	MaxFilterName   = 64
+	MaxTagName      = 64
 )
 
 type API struct {
-	store  *db.Store
+	store  db.TaskStore
 	config *config.Config
 }
 
-func NewAPI(store *db.Store, cfg *config.Config) *API {
+func NewAPI(store db.TaskStore, cfg *config.Config) *API {
 	return &API{store: store, config: cfg}
 }
 
@@ -36,54 +48,416 @@ func (a *API) Init() {
 	http.HandleFunc("/api/task", a.authMiddleware(a.taskHandler))
 	http.HandleFunc("/api/tasks", a.authMiddleware(a.tasksHandler))
 	http.HandleFunc("/api/task/done", a.authMiddleware(a.handleTaskDone))
+	http.HandleFunc("/api/task/skip", a.authMiddleware(a.handleTaskSkip))
+	http.HandleFunc("/api/occurrences", a.authMiddleware(a.occurrencesHandler))
+	http.HandleFunc("/api/holidays", a.authMiddleware(a.holidaysHandler))
+	http.HandleFunc("/api/parse", a.authMiddleware(a.parseHandler))
+	http.HandleFunc("/api/filters", a.authMiddleware(a.filtersHandler))
+	http.HandleFunc("/api/tags", a.authMiddleware(a.tagsHandler))
+	http.HandleFunc("/api/tags/merge", a.authMiddleware(a.handleMergeTags))
 }
 
 // Структуры для сериализации задач
 type JSONTask struct {
-	ID      string `json:"id"`
-	Date    string `json:"date"`
-	Title   string `json:"title"`
-	Comment string `json:"comment"`
-	Repeat  string `json:"repeat"`
+	ID         string   `json:"id"`
+	Date       string   `json:"date"`
+	Title      string   `json:"title"`
+	Comment    string   `json:"comment"`
+	Repeat     string   `json:"repeat"`
+	Time       string   `json:"time,omitempty"`
+	RepeatText string   `json:"repeat_text,omitempty"`
+	Snippet    string   `json:"snippet,omitempty"` // HTML, найденные слова выделены <mark>
+	Tags       []string `json:"tags,omitempty"`
 }
 
 type TasksResp struct {
-	Tasks []JSONTask `json:"tasks"`
+	Tasks      []JSONTask `json:"tasks"`
+	NextCursor string     `json:"next_cursor,omitempty"` // курсор следующей страницы, пустой на последней
+	Total      int        `json:"total"`                 // число задач на всех страницах
 }
 
-// Обработчик GET /api/tasks
+// Обработчик GET /api/tasks?search=...&filter=...&limit=...&sort=...&order=...&cursor=...
+// с условиями выборки из taskFilters. Строка поиска может быть запросом
+// на языке taskql, filter — имя сохранённого запроса. Общее число найденных
+// задач возвращается в поле total, курсор следующей страницы — в next_cursor;
+// они же дублируются в заголовках X-Total-Count и X-Next-Cursor.
 func (a *API) tasksHandler(w http.ResponseWriter, r *http.Request) {
-	search := r.URL.Query().Get("search")
-	limitStr := r.URL.Query().Get("limit")
-	limit := DefaultPageSize
+	q := r.URL.Query()
+	query := db.TaskQuery{
+		Search: q.Get("search"),
+		Limit:  DefaultPageSize,
+		Sort:   q.Get("sort"),
+		Cursor: q.Get("cursor"),
+	}
 
-	if limitStr != "" {
+	if limitStr := q.Get("limit"); limitStr != "" {
 		var err error
-		limit, err = strconv.Atoi(limitStr)
-		if err != nil || limit < 1 {
-			a.writeError(w, r, http.StatusBadRequest, "некорректный параметр limit")
+		query.Limit, err = strconv.Atoi(limitStr)
+		if err != nil || query.Limit < 1 {
+			a.writeError(w, r, newError(CodeInvalidParam, "limit", nil))
 			return
 		}
 	}
 
-	tasks, err := a.store.Tasks(limit, search)
+	switch q.Get("order") {
+	case "", "asc":
+	case "desc":
+		query.Desc = true
+	default:
+		a.writeError(w, r, newError(CodeInvalidParam, "order", nil))
+		return
+	}
+
+	if err := a.taskFilters(r, &query); err != nil {
+		a.writeError(w, r, err)
+		return
+	}
+
+	if taskql.IsQuery(query.Search) {
+		filter, err := taskql.Parse(query.Search)
+		if err != nil {
+			a.writeError(w, r, newError(CodeInvalidQuery, "search", err))
+			return
+		}
+		query.Search, query.Filter = "", filter
+	}
+	if name := q.Get("filter"); name != "" {
+		saved, err := a.store.GetFilter(r.Context(), name)
+		if errors.Is(err, db.ErrFilterNotFound) {
+			err = newError(CodeNotFound, "filter", err)
+		}
+		if err != nil {
+			a.writeError(w, r, err)
+			return
+		}
+		filter, err := taskql.Parse(saved.Query)
+		if err != nil {
+			a.writeError(w, r, err)
+			return
+		}
+		query.Filter = query.Filter.And(filter)
+	}
+
+	page, err := a.store.Tasks(r.Context(), query)
 	if err != nil {
-		a.writeError(w, r, http.StatusInternalServerError, "ошибка получения задач")
+		a.writeError(w, r, err)
 		return
 	}
 
-	jsonTasks := make([]JSONTask, 0, len(tasks))
-	for _, task := range tasks {
+	locale := a.locale(r)
+	jsonTasks := make([]JSONTask, 0, len(page.Tasks))
+	for _, task := range page.Tasks {
 		jsonTasks = append(jsonTasks, JSONTask{
-			ID:      strconv.FormatInt(task.ID, 10),
-			Date:    task.Date,
-			Title:   task.Title,
-			Comment: task.Comment,
-			Repeat:  task.Repeat,
+			ID:         strconv.FormatInt(task.ID, 10),
+			Date:       task.Date,
+			Title:      task.Title,
+			Comment:    task.Comment,
+			Repeat:     task.Repeat,
+			Time:       task.Time,
+			RepeatText: repeatText(task, locale),
+			Snippet:    highlight(task.Snippet),
+			Tags:       task.Tags,
 		})
 	}
 
-	a.writeJSON(w, r, http.StatusOK, TasksResp{Tasks: jsonTasks})
+	w.Header().Set("X-Total-Count", strconv.Itoa(page.Total))
+	if page.NextCursor != "" {
+		w.Header().Set("X-Next-Cursor", page.NextCursor)
+	}
+	a.writeJSON(w, r, http.StatusOK, TasksResp{Tasks: jsonTasks, NextCursor: page.NextCursor, Total: page.Total})
+}
+
+// Обработчик /api/filters — сохранённые запросы на языке taskql
+func (a *API) filtersHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		a.handleGetFilters(w, r)
+	case http.MethodPost, http.MethodPut:
+		a.handleSaveFilter(w, r)
+	case http.MethodDelete:
+		a.handleDeleteFilter(w, r)
+	default:
+		a.writeError(w, r, newError(CodeMethodNotAllowed, "", nil))
+	}
+}
+
+// Обработчик GET /api/filters — все запросы, GET /api/filters?name=... — один
+func (a *API) handleGetFilters(w http.ResponseWriter, r *http.Request) {
+	if name := r.URL.Query().Get("name"); name != "" {
+		filter, err := a.store.GetFilter(r.Context(), name)
+		if err != nil {
+			a.writeError(w, r, err)
+			return
+		}
+		a.writeJSON(w, r, http.StatusOK, filter)
+		return
+	}
+
+	filters, err := a.store.Filters(r.Context())
+	if err != nil {
+		a.writeError(w, r, err)
+		return
+	}
+	a.writeJSON(w, r, http.StatusOK, map[string][]db.Filter{"filters": filters})
+}
+
+// Обработчик POST /api/filters — новый запрос, PUT /api/filters — замена
+// текста запроса. Тело — {"name": ..., "query": ...}, запрос проверяется
+// при сохранении.
+func (a *API) handleSaveFilter(w http.ResponseWriter, r *http.Request) {
+	var filter db.Filter
+	if err := json.NewDecoder(r.Body).Decode(&filter); err != nil {
+		a.writeError(w, r, newError(CodeInvalidRequest, "", err))
+		return
+	}
+	filter.Name = strings.TrimSpace(filter.Name)
+	if filter.Name == "" {
+		a.writeError(w, r, newError(CodeMissingParam, "name", nil))
+		return
+	}
+	if utf8.RuneCountInString(filter.Name) > MaxFilterName {
+		a.writeError(w, r, newError(CodeInvalidParam, "name", nil))
+		return
+	}
+	if _, err := taskql.Parse(filter.Query); err != nil {
+		a.writeError(w, r, err)
+		return
+	}
+
+	var err error
+	if r.Method == http.MethodPost {
+		err = a.store.AddFilter(r.Context(), filter)
+	} else {
+		err = a.store.UpdateFilter(r.Context(), filter)
+	}
+	if err != nil {
+		a.writeError(w, r, err)
+		return
+	}
+	a.writeJSON(w, r, http.StatusOK, filter)
+}
+
+// Обработчик DELETE /api/filters?name=...
+func (a *API) handleDeleteFilter(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		a.writeError(w, r, newError(CodeMissingParam, "name", nil))
+		return
+	}
+	if err := a.store.DeleteFilter(r.Context(), name); err != nil {
+		a.writeError(w, r, err)
+		return
+	}
+	a.writeJSON(w, r, http.StatusOK, map[string]string{})
+}
+
+// Обработчик /api/tags — метки задач
+func (a *API) tagsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		a.handleGetTags(w, r)
+	case http.MethodPost, http.MethodPut:
+		a.handleSaveTag(w, r)
+	case http.MethodDelete:
+		a.handleDeleteTag(w, r)
+	default:
+		a.writeError(w, r, newError(CodeMethodNotAllowed, "", nil))
+	}
+}
+
+// Обработчик GET /api/tags — метки по алфавиту с числом задач
+func (a *API) handleGetTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := a.store.Tags(r.Context())
+	if err != nil {
+		a.writeError(w, r, err)
+		return
+	}
+	a.writeJSON(w, r, http.StatusOK, map[string][]db.Tag{"tags": tags})
+}
+
+// Обработчик POST /api/tags — новая метка {"name": ..., "color": ...},
+// PUT /api/tags — переименование и смена цвета метки {"id": ..., "name": ..., "color": ...}
+func (a *API) handleSaveTag(w http.ResponseWriter, r *http.Request) {
+	var tag db.Tag
+	if err := json.NewDecoder(r.Body).Decode(&tag); err != nil {
+		a.writeError(w, r, newError(CodeInvalidRequest, "", err))
+		return
+	}
+	if tag.Name = db.TagName(tag.Name); tag.Name == "" {
+		a.writeError(w, r, newError(CodeMissingParam, "name", nil))
+		return
+	}
+	if err := checkTags([]string{tag.Name}, "name"); err != nil {
+		a.writeError(w, r, err)
+		return
+	}
+	color, err := parseColor(tag.Color)
+	if err != nil {
+		a.writeError(w, r, err)
+		return
+	}
+	tag.Color = color
+
+	if r.Method == http.MethodPost {
+		err = a.store.AddTag(r.Context(), &tag)
+	} else {
+		err = a.store.UpdateTag(r.Context(), &tag)
+	}
+	if err != nil {
+		a.writeError(w, r, err)
+		return
+	}
+	a.writeJSON(w, r, http.StatusOK, tag)
+}
+
+// Обработчик DELETE /api/tags?id=... — метка снимается со всех задач
+func (a *API) handleDeleteTag(w http.ResponseWriter, r *http.Request) {
+	idStr := r.URL.Query().Get("id")
+	if idStr == "" {
+		a.writeError(w, r, newError(CodeMissingParam, "id", nil))
+		return
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		a.writeError(w, r, newError(CodeInvalidParam, "id", nil))
+		return
+	}
+	if err := a.store.DeleteTag(r.Context(), id); err != nil {
+		a.writeError(w, r, err)
+		return
+	}
+	a.writeJSON(w, r, http.StatusOK, map[string]string{})
+}
+
+// Обработчик POST /api/tags/merge — метка from заменяется меткой into
+// на всех задачах и удаляется. Тело — {"from": ..., "into": ...}.
+func (a *API) handleMergeTags(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		a.writeError(w, r, newError(CodeMethodNotAllowed, "", nil))
+		return
+	}
+	var request struct {
+		From int64 `json:"from,string"`
+		Into int64 `json:"into,string"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		a.writeError(w, r, newError(CodeInvalidRequest, "", err))
+		return
+	}
+	if err := a.store.MergeTags(r.Context(), request.From, request.Into); err != nil {
+		a.writeError(w, r, err)
+		return
+	}
+	a.writeJSON(w, r, http.StatusOK, map[string]string{})
+}
+
+// checkTags проверяет имена меток из параметра field:
+// имя не пустое и не длиннее MaxTagName символов
+func checkTags(names []string, field string) error {
+	for _, name := range names {
+		name = db.TagName(name)
+		if name == "" || utf8.RuneCountInString(name) > MaxTagName {
+			return newError(CodeInvalidParam, field, nil)
+		}
+	}
+	return nil
+}
+
+// parseColor проверяет цвет метки в виде #rrggbb и приводит его
+// к нижнему регистру; пустая строка — метка без цвета
+func parseColor(color string) (string, error) {
+	if color == "" {
+		return "", nil
+	}
+	if len(color) != 7 || color[0] != '#' {
+		return "", newError(CodeInvalidParam, "color", nil)
+	}
+	if _, err := strconv.ParseUint(color[1:], 16, 32); err != nil {
+		return "", newError(CodeInvalidParam, "color", nil)
+	}
+	return strings.ToLower(color), nil
+}
+
+// taskFilters разбирает условия выборки задач: диапазон дат from и to,
+// в том числе записанных словами, overdue=true — просроченные задачи,
+// today=true — задачи на сегодня, repeating и has_comment — true или false,
+// tag — метка задачи, параметр можно повторить.
+// Условия объединяются между собой и с поиском через "и".
+func (a *API) taskFilters(r *http.Request, query *db.TaskQuery) error {
+	q := r.URL.Query()
+	now, err := a.now(r)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range []struct {
+		name string
+		date *string
+	}{{"from", &query.From}, {"to", &query.To}} {
+		if s := q.Get(p.name); s != "" {
+			date, err := dateutil.ParseDateInput(s, now)
+			if err != nil {
+				return newError(CodeInvalidParam, p.name, err)
+			}
+			*p.date = date.Format(DateFormat)
+		}
+	}
+
+	overdue, err := boolParam(q, "overdue")
+	if err != nil {
+		return err
+	}
+	onlyToday, err := boolParam(q, "today")
+	if err != nil {
+		return err
+	}
+	today := now.Truncate(24 * time.Hour)
+	if overdue != nil && *overdue {
+		narrowDates(query, "", today.AddDate(0, 0, -1).Format(DateFormat))
+	}
+	if onlyToday != nil && *onlyToday {
+		narrowDates(query, today.Format(DateFormat), today.Format(DateFormat))
+	}
+
+	if query.Repeating, err = boolParam(q, "repeating"); err != nil {
+		return err
+	}
+	if err := checkTags(q["tag"], "tag"); err != nil {
+		return err
+	}
+	query.Tags = q["tag"]
+	query.HasComment, err = boolParam(q, "has_comment")
+	return err
+}
+
+// narrowDates сужает диапазон дат выборки до пересечения с from — to
+func narrowDates(query *db.TaskQuery, from, to string) {
+	if from > query.From {
+		query.From = from
+	}
+	if to != "" && (query.To == "" || to < query.To) {
+		query.To = to
+	}
+}
+
+// boolParam разбирает необязательный логический параметр запроса
+func boolParam(q url.Values, name string) (*bool, error) {
+	s := q.Get(name)
+	if s == "" {
+		return nil, nil
+	}
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return nil, newError(CodeInvalidParam, name, nil)
+	}
+	return &v, nil
+}
+
+// highlight экранирует фрагмент с найденными словами для вставки в HTML
+// и выделяет найденные слова тегом <mark>
+func highlight(snippet string) string {
+	return strings.NewReplacer(db.SnippetStart, "<mark>", db.SnippetEnd, "</mark>").
+		Replace(html.EscapeString(snippet))
 }
 
 // Обработчик /api/nextdate
@@ -93,28 +467,42 @@ func (a *API) nextDateHandler(w http.ResponseWriter, r *http.Request) {
 	repeat := r.FormValue("repeat")
 
 	// Проверка обязательных параметров
-	if dateParam == "" || repeat == "" {
-		a.writeError(w, r, http.StatusBadRequest, "не указаны параметры date или repeat")
+	if dateParam == "" {
+		a.writeError(w, r, newError(CodeMissingParam, "date", nil))
+		return
+	}
+	if repeat == "" {
+		a.writeError(w, r, newError(CodeMissingParam, "repeat", nil))
 		return
 	}
 
-	// Парсинг даты 'now'
+	// Парсинг даты 'now', возможно со временем
 	var now time.Time
 	if nowParam == "" {
-		now = time.Now()
+		var err error
+		if now, err = a.now(r); err != nil {
+			a.writeError(w, r, err)
+			return
+		}
 	} else {
 		var err error
-		now, err = time.Parse(dateutil.DateFormat, nowParam)
+		now, _, err = dateutil.ParseDateTime(nowParam)
 		if err != nil {
-			a.writeError(w, r, http.StatusBadRequest, "некорректный параметр 'now'")
+			a.writeError(w, r, newError(CodeInvalidParam, "now", err))
 			return
 		}
 	}
 
+	cal, err := a.calendar(r.Context())
+	if err != nil {
+		a.writeError(w, r, err)
+		return
+	}
+
 	// Вызов функции из пакета dateutil
-	nextDate, err := dateutil.NextDate(now, dateParam, repeat)
+	nextDate, err := dateutil.NextDate(now, dateParam, repeat, cal)
 	if err != nil {
-		a.writeError(w, r, http.StatusBadRequest, err.Error())
+		a.writeError(w, r, err)
 		return
 	}
 
@@ -125,6 +513,265 @@ func (a *API) nextDateHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Запрос /api/nextdate: now=%s, date=%s, repeat=%s", nowParam, dateParam, repeat)
 }
 
+// Обработчик GET /api/occurrences?date=...&repeat=...&from=...&to=...&limit=...
+// или GET /api/occurrences?id=...&from=...&to=...&limit=...
+func (a *API) occurrencesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		a.writeError(w, r, newError(CodeMethodNotAllowed, "", nil))
+		return
+	}
+
+	q := r.URL.Query()
+	dateParam := q.Get("date")
+	repeat := q.Get("repeat")
+	var rule dateutil.Rule
+
+	if id := q.Get("id"); id != "" {
+		task, err := a.store.GetTask(r.Context(), id)
+		if err != nil {
+			a.writeError(w, r, err)
+			return
+		}
+		dateParam = task.Date
+		if task.Time != "" {
+			dateParam += " " + task.Time
+		}
+		if task.Repeat != "" {
+			rule, err = a.taskRule(r.Context(), task)
+			if err != nil {
+				a.writeError(w, r, err)
+				return
+			}
+		}
+	} else if repeat != "" {
+		var err error
+		rule, err = dateutil.ParseRule(repeat)
+		if err == nil {
+			rule, err = a.withCalendar(r.Context(), rule)
+		}
+		if err != nil {
+			a.writeError(w, r, err)
+			return
+		}
+	}
+
+	if dateParam == "" {
+		a.writeError(w, r, newError(CodeMissingParam, "date", nil))
+		return
+	}
+	start, withTime, err := dateutil.ParseDateTime(dateParam)
+	if err != nil {
+		a.writeError(w, r, newError(CodeInvalidParam, "date", err))
+		return
+	}
+	layout := DateFormat
+	if withTime || (rule != nil && dateutil.IsSubDaily(rule)) {
+		layout = dateutil.DateTimeFormat
+	}
+
+	now, err := a.now(r)
+	if err != nil {
+		a.writeError(w, r, err)
+		return
+	}
+	from := now.Truncate(24 * time.Hour)
+	if s := q.Get("from"); s != "" {
+		if from, err = time.Parse(DateFormat, s); err != nil {
+			a.writeError(w, r, newError(CodeInvalidParam, "from", err))
+			return
+		}
+	}
+
+	var to time.Time
+	if s := q.Get("to"); s != "" {
+		if to, err = time.Parse(DateFormat, s); err != nil || to.Before(from) {
+			a.writeError(w, r, newError(CodeInvalidParam, "to", nil))
+			return
+		}
+	}
+
+	limit := DefaultPageSize
+	if s := q.Get("limit"); s != "" {
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > MaxOccurrences {
+			a.writeError(w, r, newError(CodeInvalidParam, "limit", nil))
+			return
+		}
+	}
+
+	dates := make([]string, 0)
+	if rule == nil {
+		if !start.Before(from) && (to.IsZero() || start.Before(to.AddDate(0, 0, 1))) {
+			dates = append(dates, start.Format(layout))
+		}
+	} else {
+		for date := range dateutil.Occurrences(rule, start, from, to, limit) {
+			dates = append(dates, date.Format(layout))
+		}
+	}
+
+	a.writeJSON(w, r, http.StatusOK, map[string][]string{"dates": dates})
+}
+
+type JSONHoliday struct {
+	Date    string `json:"date"`
+	Title   string `json:"title"`
+	Workday bool   `json:"workday"`
+}
+
+// Обработчик /api/holidays — производственный календарь
+func (a *API) holidaysHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		a.handleGetHolidays(w, r)
+	case http.MethodPost:
+		a.handleUploadHolidays(w, r)
+	case http.MethodDelete:
+		a.handleDeleteHolidays(w, r)
+	default:
+		a.writeError(w, r, newError(CodeMethodNotAllowed, "", nil))
+	}
+}
+
+// Обработчик GET /api/holidays
+func (a *API) handleGetHolidays(w http.ResponseWriter, r *http.Request) {
+	holidays, err := a.store.Holidays(r.Context())
+	if err != nil {
+		a.writeError(w, r, err)
+		return
+	}
+
+	jsonHolidays := make([]JSONHoliday, 0, len(holidays))
+	for _, h := range holidays {
+		jsonHolidays = append(jsonHolidays, JSONHoliday(h))
+	}
+	a.writeJSON(w, r, http.StatusOK, map[string][]JSONHoliday{"holidays": jsonHolidays})
+}
+
+// Обработчик POST /api/holidays?replace=true. Календарь в формате .ics или CSV
+// передаётся телом запроса или файлом "file" в multipart/form-data.
+func (a *API) handleUploadHolidays(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, MaxCalendarSize)
+
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			a.writeError(w, r, newError(CodeMissingParam, "file", nil))
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		a.writeError(w, r, newError(CodeInvalidCalendar, "file", err))
+		return
+	}
+
+	parsed, err := dateutil.ParseCalendar(data)
+	if err != nil {
+		a.writeError(w, r, err)
+		return
+	}
+
+	holidays := make([]db.Holiday, 0, len(parsed))
+	for _, h := range parsed {
+		holidays = append(holidays, db.Holiday{Date: h.Date.Format(DateFormat), Title: h.Title, Workday: h.Workday})
+	}
+
+	replace := r.URL.Query().Get("replace") == "true"
+	if err := a.store.SaveHolidays(r.Context(), holidays, replace); err != nil {
+		a.writeError(w, r, err)
+		return
+	}
+
+	a.writeJSON(w, r, http.StatusOK, map[string]int{"loaded": len(holidays)})
+}
+
+// Обработчик DELETE /api/holidays?date=... — без даты календарь очищается целиком
+func (a *API) handleDeleteHolidays(w http.ResponseWriter, r *http.Request) {
+	date := r.URL.Query().Get("date")
+	if date != "" {
+		if _, err := time.Parse(DateFormat, date); err != nil {
+			a.writeError(w, r, newError(CodeInvalidParam, "date", err))
+			return
+		}
+	}
+
+	if err := a.store.DeleteHolidays(r.Context(), date); err != nil {
+		a.writeError(w, r, err)
+		return
+	}
+
+	a.writeJSON(w, r, http.StatusOK, map[string]string{})
+}
+
+// calendar читает производственный календарь из базы
+func (a *API) calendar(ctx context.Context) (*dateutil.Calendar, error) {
+	holidays, err := a.store.Holidays(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	calendar := make([]dateutil.Holiday, 0, len(holidays))
+	for _, h := range holidays {
+		date, err := time.Parse(DateFormat, h.Date)
+		if err != nil {
+			return nil, fmt.Errorf("некорректная дата %q в календаре", h.Date)
+		}
+		calendar = append(calendar, dateutil.Holiday{Date: date, Title: h.Title, Workday: h.Workday})
+	}
+	return dateutil.NewCalendar(calendar), nil
+}
+
+// withCalendar дополняет правило производственным календарём из базы
+func (a *API) withCalendar(ctx context.Context, rule dateutil.Rule) (dateutil.Rule, error) {
+	cal, err := a.calendar(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return dateutil.WithCalendar(rule, cal), nil
+}
+
+// Обработчик GET /api/parse?date=...&repeat=... — разбор даты и правила,
+// в том числе записанных словами, для предпросмотра в редакторе задачи
+func (a *API) parseHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		a.writeError(w, r, newError(CodeMethodNotAllowed, "", nil))
+		return
+	}
+
+	now, err := a.now(r)
+	if err != nil {
+		a.writeError(w, r, err)
+		return
+	}
+
+	q := r.URL.Query()
+	resp := map[string]string{}
+	start := now.Truncate(24 * time.Hour)
+	if s := q.Get("date"); s != "" {
+		if start, err = dateutil.ParseDateInput(s, now); err != nil {
+			a.writeError(w, r, newError(CodeInvalidDate, "date", err))
+			return
+		}
+		resp["date"] = start.Format(DateFormat)
+	}
+	if s := q.Get("repeat"); s != "" {
+		rule, err := dateutil.ParseRuleInput(s, start)
+		if err != nil {
+			a.writeError(w, r, err)
+			return
+		}
+		resp["repeat"] = rule.String()
+		resp["repeat_text"] = dateutil.Describe(rule, a.locale(r))
+	}
+
+	a.writeJSON(w, r, http.StatusOK, resp)
+}
+
 // Основной обработчик для /api/task
 func (a *API) taskHandler(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
@@ -137,7 +784,7 @@ func (a *API) taskHandler(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPut:
 		a.handleUpdateTask(w, r)
 	default:
-		a.writeError(w, r, http.StatusMethodNotAllowed, "Метод не поддерживается")
+		a.writeError(w, r, newError(CodeMethodNotAllowed, "", nil))
 	}
 }
 
@@ -145,36 +792,57 @@ func (a *API) taskHandler(w http.ResponseWriter, r *http.Request) {
 func (a *API) handleGetTask(w http.ResponseWriter, r *http.Request) {
 	id := r.URL.Query().Get("id")
 	if id == "" {
-		a.writeError(w, r, http.StatusBadRequest, "Не указан идентификатор")
+		a.writeError(w, r, newError(CodeMissingParam, "id", nil))
 		return
 	}
 
-	task, err := a.store.GetTask(id)
+	task, err := a.store.GetTask(r.Context(), id)
 	if err != nil {
-		a.writeError(w, r, http.StatusNotFound, err.Error())
+		a.writeError(w, r, err)
 		return
 	}
 
-	a.writeJSON(w, r, http.StatusOK, map[string]string{
+	resp := map[string]interface{}{
 		"id":      strconv.FormatInt(task.ID, 10),
 		"date":    task.Date,
 		"title":   task.Title,
 		"comment": task.Comment,
 		"repeat":  task.Repeat,
-	})
+	}
+	if task.Time != "" {
+		resp["time"] = task.Time
+	}
+	if task.Remaining > 0 {
+		resp["remaining"] = strconv.FormatInt(task.Remaining, 10)
+	}
+	if task.Repeat != "" {
+		resp["repeat_mode"] = "schedule"
+		if rule, err := task.Rule(); err == nil && dateutil.RepeatsFromDone(rule) {
+			resp["repeat_mode"] = "done"
+		}
+		resp["repeat_text"] = repeatText(task, a.locale(r))
+	}
+	if len(task.Tags) > 0 {
+		resp["tags"] = task.Tags
+	}
+
+	w.Header().Set("ETag", etag(task))
+	a.writeJSON(w, r, http.StatusOK, resp)
 }
 
 // Обработчик POST /api/task
 func (a *API) handleAddTask(w http.ResponseWriter, r *http.Request) {
 	var request struct {
-		Date    string `json:"date"`
-		Title   string `json:"title"`
-		Comment string `json:"comment"`
-		Repeat  string `json:"repeat"`
+		Date    string   `json:"date"`
+		Title   string   `json:"title"`
+		Comment string   `json:"comment"`
+		Repeat  string   `json:"repeat"`
+		Time    string   `json:"time"`
+		Tags    []string `json:"tags"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		a.writeError(w, r, http.StatusBadRequest, "Ошибка десериализации JSON")
+		a.writeError(w, r, newError(CodeInvalidRequest, "", err))
 		return
 	}
 
@@ -183,53 +851,93 @@ func (a *API) handleAddTask(w http.ResponseWriter, r *http.Request) {
 		Title:   request.Title,
 		Comment: request.Comment,
 		Repeat:  request.Repeat,
+		Tags:    request.Tags,
+	}
+	if err := checkTags(task.Tags, "tags"); err != nil {
+		a.writeError(w, r, err)
+		return
 	}
 
 	if task.Title == "" {
-		a.writeError(w, r, http.StatusBadRequest, "Не указан заголовок задачи")
+		a.writeError(w, r, newError(CodeEmptyTitle, "title", nil))
 		return
 	}
 
-	now := time.Now().Truncate(24 * time.Hour)
+	now, err := a.now(r)
+	if err != nil {
+		a.writeError(w, r, err)
+		return
+	}
+	today := now.Truncate(24 * time.Hour)
 	if task.Date == "" {
-		task.Date = now.Format(DateFormat)
+		task.Date = today.Format(DateFormat)
 	}
 
-	t, err := time.Parse(DateFormat, task.Date)
+	// Дата может быть записана словами: "завтра", "next friday"
+	t, err := dateutil.ParseDateInput(task.Date, now)
 	if err != nil {
-		a.writeError(w, r, http.StatusBadRequest, "Некорректный формат даты")
+		a.writeError(w, r, newError(CodeInvalidDate, "date", err))
+		return
+	}
+	task.Date = t.Format(DateFormat)
+
+	if task.Time, err = parseTime(request.Time); err != nil {
+		a.writeError(w, r, err)
 		return
 	}
-	t = t.Truncate(24 * time.Hour)
+	start, _ := task.Start()
 
+	past := t.Before(today)
 	if task.Repeat != "" {
-		_, err := dateutil.NextDate(now, task.Date, task.Repeat)
+		rule, err := dateutil.ParseRuleInput(task.Repeat, start)
 		if err != nil {
-			a.writeError(w, r, http.StatusBadRequest, "Некорректное правило повторения")
+			a.writeError(w, r, newError(CodeInvalidRule, "repeat", err))
 			return
 		}
-	}
+		task.Repeat = rule.String()
+		task.Remaining = int64(dateutil.RuleEnd(rule).Count)
+
+		// Задача, повторяющаяся в течение дня, устаревает уже в прошлом часе
+		subDaily := dateutil.IsSubDaily(rule)
+		if subDaily {
+			task.Time = start.Format(dateutil.TimeFormat)
+			past = start.Before(now)
+		}
 
-	if t.Before(now) {
-		if task.Repeat == "" {
-			task.Date = now.Format(DateFormat)
-		} else {
-			nextDate, err := dateutil.NextDate(now, task.Date, task.Repeat)
+		// Следующая дата нужна, только если указанная уже прошла
+		if past {
+			rule, err := a.withCalendar(r.Context(), rule)
 			if err != nil {
-				a.writeError(w, r, http.StatusBadRequest, err.Error())
+				a.writeError(w, r, err)
 				return
 			}
-			task.Date = nextDate
+			next, err := dateutil.NextAt(rule, now, start)
+			if err != nil {
+				a.writeError(w, r, err)
+				return
+			}
+			task.Remaining = remainingAt(rule, task.Remaining, start, next)
+			task.Date = next.Format(DateFormat)
+			if subDaily {
+				task.Time = next.Format(dateutil.TimeFormat)
+			}
 		}
+	} else if past {
+		task.Date = today.Format(DateFormat)
 	}
 
-	id, err := a.store.AddTask(&task)
+	id, err := a.store.AddTask(r.Context(), &task)
 	if err != nil {
-		a.writeError(w, r, http.StatusInternalServerError, err.Error())
+		a.writeError(w, r, err)
 		return
 	}
 
-	a.writeJSON(w, r, http.StatusOK, map[string]interface{}{"id": id})
+	// Дату и правило возвращаем в канонической записи, если они были заданы словами
+	a.writeJSON(w, r, http.StatusOK, map[string]interface{}{
+		"id":     id,
+		"date":   task.Date,
+		"repeat": task.Repeat,
+	})
 }
 
 // Обработчик PUT /api/task
@@ -240,16 +948,25 @@ func (a *API) handleUpdateTask(w http.ResponseWriter, r *http.Request) {
 		Title   string `json:"title"`
 		Comment string `json:"comment"`
 		Repeat  string `json:"repeat"`
+		Time    string `json:"time"`
+		// без поля tags метки задачи не меняются, пустой список снимает их
+		Tags []string `json:"tags"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		a.writeError(w, r, http.StatusBadRequest, "Ошибка десериализации JSON")
+		a.writeError(w, r, newError(CodeInvalidRequest, "", err))
 		return
 	}
 
 	id, err := strconv.ParseInt(request.ID, 10, 64)
 	if err != nil {
-		a.writeError(w, r, http.StatusBadRequest, "Некорректный ID задачи")
+		a.writeError(w, r, newError(CodeInvalidID, "id", nil))
+		return
+	}
+
+	version, err := a.ifMatch(r)
+	if err != nil {
+		a.writeError(w, r, err)
 		return
 	}
 
@@ -259,51 +976,108 @@ func (a *API) handleUpdateTask(w http.ResponseWriter, r *http.Request) {
 		Title:   request.Title,
 		Comment: request.Comment,
 		Repeat:  request.Repeat,
+		Version: version,
+		Tags:    request.Tags,
+	}
+	if err := checkTags(task.Tags, "tags"); err != nil {
+		a.writeError(w, r, err)
+		return
 	}
 
 	if task.Title == "" {
-		a.writeError(w, r, http.StatusBadRequest, "Не указан заголовок задачи")
+		a.writeError(w, r, newError(CodeEmptyTitle, "title", nil))
 		return
 	}
 
-	now := time.Now().Truncate(24 * time.Hour)
+	now, err := a.now(r)
+	if err != nil {
+		a.writeError(w, r, err)
+		return
+	}
+	today := now.Truncate(24 * time.Hour)
 	if task.Date == "" {
-		task.Date = now.Format(DateFormat)
+		task.Date = today.Format(DateFormat)
 	}
 
-	parsedDate, err := time.Parse(DateFormat, task.Date)
+	parsedDate, err := dateutil.ParseDateInput(task.Date, now)
 	if err != nil {
-		a.writeError(w, r, http.StatusBadRequest, "Некорректный формат даты")
+		a.writeError(w, r, newError(CodeInvalidDate, "date", err))
+		return
+	}
+	task.Date = parsedDate.Format(DateFormat)
+
+	if task.Time, err = parseTime(request.Time); err != nil {
+		a.writeError(w, r, err)
 		return
 	}
+	start, _ := task.Start()
 
-	if parsedDate.Before(now) && task.Repeat != "" {
-		nextDate, err := dateutil.NextDate(now, task.Date, task.Repeat)
+	if task.Repeat != "" {
+		rule, err := dateutil.ParseRuleInput(task.Repeat, start)
 		if err != nil {
-			a.writeError(w, r, http.StatusBadRequest, err.Error())
+			a.writeError(w, r, newError(CodeInvalidRule, "repeat", err))
 			return
 		}
-		task.Date = nextDate
+		task.Repeat = rule.String()
+		task.Remaining = int64(dateutil.RuleEnd(rule).Count)
+
+		past := parsedDate.Before(today)
+		subDaily := dateutil.IsSubDaily(rule)
+		if subDaily {
+			task.Time = start.Format(dateutil.TimeFormat)
+			past = start.Before(now)
+		}
+
+		if past {
+			rule, err := a.withExceptions(r.Context(), request.ID, rule)
+			if err == nil {
+				rule, err = a.withCalendar(r.Context(), rule)
+			}
+			if err != nil {
+				a.writeError(w, r, err)
+				return
+			}
+			next, err := dateutil.NextAt(rule, now, start)
+			if err != nil {
+				a.writeError(w, r, err)
+				return
+			}
+			task.Remaining = remainingAt(rule, task.Remaining, start, next)
+			task.Date = next.Format(DateFormat)
+			if subDaily {
+				task.Time = next.Format(dateutil.TimeFormat)
+			}
+		}
 	}
 
-	if err := a.store.UpdateTask(&task); err != nil {
-		a.writeError(w, r, http.StatusInternalServerError, err.Error())
+	if err := a.store.UpdateTask(r.Context(), &task); err != nil {
+		a.writeError(w, r, precondition(err, version))
 		return
 	}
 
-	a.writeJSON(w, r, http.StatusOK, map[string]interface{}{})
+	w.Header().Set("ETag", etag(&task))
+	a.writeJSON(w, r, http.StatusOK, map[string]interface{}{
+		"date":   task.Date,
+		"repeat": task.Repeat,
+	})
 }
 
 // Обработчик DELETE /api/task
 func (a *API) handleDeleteTask(w http.ResponseWriter, r *http.Request) {
 	id := r.URL.Query().Get("id")
 	if id == "" {
-		a.writeError(w, r, http.StatusBadRequest, "Не указан ID")
+		a.writeError(w, r, newError(CodeMissingParam, "id", nil))
+		return
+	}
+
+	version, err := a.ifMatch(r)
+	if err != nil {
+		a.writeError(w, r, err)
 		return
 	}
 
-	if err := a.store.DeleteTask(id); err != nil {
-		a.writeError(w, r, http.StatusInternalServerError, err.Error())
+	if err := a.store.DeleteTask(r.Context(), id, version); err != nil {
+		a.writeError(w, r, precondition(err, version))
 		return
 	}
 
@@ -313,47 +1087,357 @@ func (a *API) handleDeleteTask(w http.ResponseWriter, r *http.Request) {
 // Обработчик POST /api/task/done
 func (a *API) handleTaskDone(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		a.writeError(w, r, http.StatusMethodNotAllowed, "Метод не поддерживается")
+		a.writeError(w, r, newError(CodeMethodNotAllowed, "", nil))
 		return
 	}
 
 	id := r.URL.Query().Get("id")
 	if id == "" {
-		a.writeError(w, r, http.StatusBadRequest, "Не указан ID")
+		a.writeError(w, r, newError(CodeMissingParam, "id", nil))
+		return
+	}
+
+	version, err := a.ifMatch(r)
+	if err != nil {
+		a.writeError(w, r, err)
+		return
+	}
+
+	now, err := a.now(r)
+	if err != nil {
+		a.writeError(w, r, err)
 		return
 	}
 
-	task, err := a.store.GetTask(id)
+	cal, err := a.calendar(r.Context())
 	if err != nil {
-		a.writeError(w, r, http.StatusNotFound, err.Error())
+		a.writeError(w, r, err)
 		return
 	}
 
-	now := time.Now().Truncate(24 * time.Hour)
+	// Задача читается и переносится или удаляется в одной транзакции
+	task, err := a.store.CompleteTask(r.Context(), id, version, func(task *db.Task, except []string) (string, string, error) {
+		return nextAfterDone(task, except, cal, now)
+	})
+	if err != nil {
+		a.writeError(w, r, precondition(err, version))
+		return
+	}
+
+	if task != nil {
+		w.Header().Set("ETag", etag(task))
+	}
+	a.writeJSON(w, r, http.StatusOK, map[string]interface{}{})
+}
+
+// nextAfterDone возвращает дату и время, на которые переносится задача,
+// выполненная в момент now. Пустая дата означает, что задача выполнена окончательно.
+func nextAfterDone(task *db.Task, except []string, cal *dateutil.Calendar, now time.Time) (string, string, error) {
+	// Разовая задача и последнее повторение серии просто удаляются
+	if task.Repeat == "" || task.Remaining == 1 {
+		return "", "", nil
+	}
+
+	rule, err := seriesRule(task, except, cal)
+	if err != nil {
+		return "", "", err
+	}
+	start, err := task.Start()
+	if err != nil {
+		return "", "", err
+	}
+	subDaily := dateutil.IsSubDaily(rule)
+	// В режиме отсчёта от выполнения серия начинается заново с текущего момента,
+	// а у правил по дням — с сегодняшнего дня в то же время суток
+	if dateutil.RepeatsFromDone(rule) {
+		if subDaily {
+			start = now
+		} else {
+			start = now.Truncate(24 * time.Hour).Add(start.Sub(start.Truncate(24 * time.Hour)))
+		}
+	}
+	next, err := dateutil.NextAt(rule, now, start)
+	if errors.Is(err, dateutil.ErrSeriesEnded) {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	nextTime := task.Time
+	if subDaily {
+		nextTime = next.Format(dateutil.TimeFormat)
+	}
+	return next.Format(DateFormat), nextTime, nil
+}
+
+// Обработчик POST /api/task/skip?id=...
+func (a *API) handleTaskSkip(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		a.writeError(w, r, newError(CodeMethodNotAllowed, "", nil))
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		a.writeError(w, r, newError(CodeMissingParam, "id", nil))
+		return
+	}
+	version, err := a.ifMatch(r)
+	if err != nil {
+		a.writeError(w, r, err)
+		return
+	}
+
+	// Следующая дата вычисляется по прочитанной версии задачи, поэтому перенос
+	// сохраняется, только если задачу с тех пор не изменили
+	task, err := a.store.GetTask(r.Context(), id)
+	if err != nil {
+		a.writeError(w, r, err)
+		return
+	}
+	if version > 0 && task.Version != version {
+		a.writeError(w, r, precondition(db.ErrConflict, version))
+		return
+	}
 	if task.Repeat == "" {
-		if err := a.store.DeleteTask(id); err != nil {
-			a.writeError(w, r, http.StatusInternalServerError, err.Error())
+		a.writeError(w, r, newError(CodeSkipNotAllowed, "id", errors.New("пропустить можно только повторяющуюся задачу")))
+		return
+	}
+
+	rule, err := a.taskRule(r.Context(), task)
+	if err != nil {
+		a.writeError(w, r, err)
+		return
+	}
+	// Пропуски хранятся по датам, поэтому для повторений в течение дня не подходят
+	if dateutil.IsSubDaily(rule) {
+		a.writeError(w, r, newError(CodeSkipNotAllowed, "id", errors.New("пропустить можно только задачу, повторяющуюся по дням")))
+		return
+	}
+	start, err := time.Parse(DateFormat, task.Date)
+	if err != nil {
+		a.writeError(w, r, err)
+		return
+	}
+
+	// Следующая дата ищется после текущей даты задачи, даже если она в будущем
+	now, err := a.now(r)
+	if err != nil {
+		a.writeError(w, r, err)
+		return
+	}
+	now = now.Truncate(24 * time.Hour)
+	if start.After(now) {
+		now = start
+	}
+	next, err := dateutil.WithExcept(rule, []time.Time{start}).Next(now, start)
+	if errors.Is(err, dateutil.ErrSeriesEnded) {
+		if err := a.store.DeleteTask(r.Context(), id, task.Version); err != nil {
+			a.writeError(w, r, precondition(err, version))
 			return
 		}
-	} else {
-		nextDate, err := dateutil.NextDate(now, task.Date, task.Repeat)
-		if err != nil {
-			a.writeError(w, r, http.StatusBadRequest, err.Error())
-			return
+		a.writeJSON(w, r, http.StatusOK, map[string]interface{}{})
+		return
+	}
+	if err != nil {
+		a.writeError(w, r, err)
+		return
+	}
+
+	if err := a.store.SkipDate(r.Context(), task, version, next.Format(DateFormat)); err != nil {
+		a.writeError(w, r, precondition(err, version))
+		return
+	}
+
+	w.Header().Set("ETag", etag(task))
+
+	a.writeJSON(w, r, http.StatusOK, map[string]string{"date": next.Format(DateFormat)})
+}
+
+// locale возвращает язык ответа: из параметра lang или заголовка Accept-Language
+func (a *API) locale(r *http.Request) string {
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		return lang
+	}
+	// Берём первый язык из списка вида "en-US,en;q=0.9,ru;q=0.8"
+	lang, _, _ := strings.Cut(r.Header.Get("Accept-Language"), ",")
+	lang, _, _ = strings.Cut(lang, ";")
+	return strings.TrimSpace(lang)
+}
+
+// repeatText описывает правило повторения задачи словами
+func repeatText(task *db.Task, locale string) string {
+	if task.Repeat == "" {
+		return ""
+	}
+	rule, err := task.Rule()
+	if err != nil {
+		return task.Repeat
+	}
+	return dateutil.Describe(rule, locale)
+}
+
+// location возвращает часовой пояс запроса: из заголовка X-Timezone,
+// cookie tz или, если они не заданы, из настроек сервера
+func (a *API) location(r *http.Request) (*time.Location, error) {
+	name := r.Header.Get("X-Timezone")
+	if name == "" {
+		if cookie, err := r.Cookie("tz"); err == nil {
+			name, _ = url.QueryUnescape(cookie.Value)
 		}
-		if err := a.store.UpdateDate(nextDate, id); err != nil {
-			a.writeError(w, r, http.StatusInternalServerError, err.Error())
-			return
+	}
+	if name == "" {
+		if a.config.Location != nil {
+			return a.config.Location, nil
 		}
+		return time.Local, nil
 	}
 
-	a.writeJSON(w, r, http.StatusOK, map[string]interface{}{})
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, newError(CodeInvalidTimezone, "X-Timezone", fmt.Errorf("некорректный часовой пояс %q", name))
+	}
+	return loc, nil
+}
+
+// now возвращает текущие дату и время в часовом поясе запроса с точностью
+// до минуты. Даты задач хранятся без часового пояса, поэтому местное время
+// записывается так же, в UTC: сравнение с датами задач остаётся корректным.
+func (a *API) now(r *http.Request) (time.Time, error) {
+	loc, err := a.location(r)
+	if err != nil {
+		return time.Time{}, err
+	}
+	local := time.Now().In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day(),
+		local.Hour(), local.Minute(), 0, 0, time.UTC), nil
+}
+
+// parseTime проверяет время задачи и приводит его к виду ЧЧ:ММ
+func parseTime(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	t, err := time.Parse(dateutil.TimeFormat, value)
+	if err != nil {
+		return "", newError(CodeInvalidTime, "time", err)
+	}
+	return t.Format(dateutil.TimeFormat), nil
+}
+
+// taskRule возвращает правило повторения задачи с учётом пропущенных дат,
+// оставшегося числа повторений и производственного календаря
+func (a *API) taskRule(ctx context.Context, task *db.Task) (dateutil.Rule, error) {
+	except, err := a.store.Exceptions(ctx, strconv.FormatInt(task.ID, 10))
+	if err != nil {
+		return nil, err
+	}
+	cal, err := a.calendar(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return seriesRule(task, except, cal)
+}
+
+// seriesRule возвращает правило повторения задачи с пропущенными датами except,
+// оставшимся числом повторений и рабочими днями по календарю cal
+func seriesRule(task *db.Task, except []string, cal *dateutil.Calendar) (dateutil.Rule, error) {
+	rule, err := task.Rule()
+	if err != nil {
+		return nil, err
+	}
+	rule = dateutil.WithCalendar(rule, cal)
+	rule, err = exceptDates(rule, except)
+	if err != nil {
+		return nil, err
+	}
+
+	// Часть повторений серии уже выполнена, считаем от текущей даты задачи
+	if task.Remaining > 0 {
+		rule = dateutil.Bounded{Rule: rule, End: dateutil.End{Count: int(task.Remaining)}}
+	}
+	return rule, nil
+}
+
+// remainingAt возвращает, сколько повторений серии с датой начала start
+// останется, если перенести её на дату next: прошедшие даты тоже расходуют count
+func remainingAt(rule dateutil.Rule, remaining int64, start, next time.Time) int64 {
+	if remaining == 0 {
+		return 0
+	}
+	for date := range dateutil.Occurrences(rule, start, start, next, 0) {
+		if !date.Before(next) {
+			break
+		}
+		remaining--
+	}
+	return remaining
+}
+
+// withExceptions дополняет правило пропущенными датами задачи
+func (a *API) withExceptions(ctx context.Context, id string, rule dateutil.Rule) (dateutil.Rule, error) {
+	except, err := a.store.Exceptions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return exceptDates(rule, except)
+}
+
+// exceptDates исключает из правила даты except
+func exceptDates(rule dateutil.Rule, except []string) (dateutil.Rule, error) {
+	if len(except) == 0 {
+		return rule, nil
+	}
+	dates, err := dateutil.ParseDates(except)
+	if err != nil {
+		return nil, err
+	}
+	return dateutil.WithExcept(rule, dates), nil
+}
+
+// etag возвращает ETag задачи — её версию в кавычках
+func etag(task *db.Task) string {
+	return fmt.Sprintf(`"%d"`, task.Version)
+}
+
+// ifMatch возвращает версию задачи из заголовка If-Match или 0, если заголовка
+// нет или в нём "*". Без заголовка запрос отклоняется, когда If-Match
+// обязателен по настройке TODO_REQUIRE_IF_MATCH.
+func (a *API) ifMatch(r *http.Request) (int64, error) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "" {
+		if a.config.RequireIfMatch {
+			return 0, newError(CodePreconditionRequired, "", nil)
+		}
+		return 0, nil
+	}
+	if value == "*" {
+		return 0, nil
+	}
+
+	// Слабые ETag (W/"...") для If-Match не подходят и ни с чем не совпадают
+	quoted := len(value) > 2 && value[0] == '"' && value[len(value)-1] == '"'
+	version, err := strconv.ParseInt(strings.Trim(value, `"`), 10, 64)
+	if !quoted || err != nil || version < 1 {
+		return 0, newError(CodePreconditionFailed, "", fmt.Errorf("некорректный заголовок If-Match: %s", value))
+	}
+	return version, nil
+}
+
+// precondition заменяет конфликт версий ошибкой условного запроса,
+// если версия задачи пришла в заголовке If-Match
+func precondition(err error, version int64) error {
+	if version > 0 && errors.Is(err, db.ErrConflict) {
+		return newError(CodePreconditionFailed, "", err)
+	}
+	return err
 }
 
 // Обработчик POST /api/signin
 func (a *API) handleSignIn(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		a.writeError(w, r, http.StatusMethodNotAllowed, "Метод не поддерживается")
+		a.writeError(w, r, newError(CodeMethodNotAllowed, "", nil))
 		return
 	}
 
@@ -361,7 +1445,7 @@ func (a *API) handleSignIn(w http.ResponseWriter, r *http.Request) {
 		Password string `json:"password"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		a.writeError(w, r, http.StatusBadRequest, "Ошибка формата запроса")
+		a.writeError(w, r, newError(CodeInvalidRequest, "", err))
 		return
 	}
 
@@ -372,7 +1456,7 @@ func (a *API) handleSignIn(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if request.Password != a.config.Password {
-		a.writeError(w, r, http.StatusUnauthorized, "Неверный пароль")
+		a.writeError(w, r, newError(CodeWrongPassword, "password", nil))
 		return
 	}
 
@@ -384,7 +1468,7 @@ func (a *API) handleSignIn(w http.ResponseWriter, r *http.Request) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenString, err := token.SignedString([]byte(envPassword))
 	if err != nil {
-		a.writeError(w, r, http.StatusInternalServerError, "Ошибка генерации токена")
+		a.writeError(w, r, err)
 		return
 	}
 
@@ -410,7 +1494,7 @@ func (a *API) authMiddleware(next http.HandlerFunc) http.HandlerFunc {
 
 		cookie, err := r.Cookie("token")
 		if err != nil {
-			a.writeError(w, r, http.StatusUnauthorized, "Требуется аутентификация")
+			a.writeError(w, r, newError(CodeUnauthorized, "", nil))
 			return
 		}
 
@@ -418,19 +1502,19 @@ func (a *API) authMiddleware(next http.HandlerFunc) http.HandlerFunc {
 			return []byte(a.config.Password), nil
 		})
 		if err != nil || !token.Valid {
-			a.writeError(w, r, http.StatusUnauthorized, "Неверный токен")
+			a.writeError(w, r, newError(CodeInvalidToken, "", err))
 			return
 		}
 
 		claims, ok := token.Claims.(jwt.MapClaims)
 		if !ok {
-			a.writeError(w, r, http.StatusUnauthorized, "Ошибка формата токена")
+			a.writeError(w, r, newError(CodeInvalidToken, "", errors.New("ошибка формата токена")))
 			return
 		}
 
 		currentHash := sha256.Sum256([]byte(a.config.Password))
 		if hex.EncodeToString(currentHash[:]) != claims["hash"] {
-			a.writeError(w, r, http.StatusUnauthorized, "Токен устарел")
+			a.writeError(w, r, newError(CodeInvalidToken, "", errors.New("токен устарел")))
 			return
 		}
 
@@ -459,7 +1543,3 @@ func (a *API) writeJSON(w http.ResponseWriter, r *http.Request, status int, data
 		log.Printf("Ошибка сериализации JSON: %v", err)
 	}
 }
-
-func (a *API) writeError(w http.ResponseWriter, r *http.Request, status int, message string) {
-	a.writeJSON(w, r, status, map[string]string{"error": message})
-}
@@ -0,0 +1,218 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"go1f/pkg/dateutil"
+	"go1f/pkg/db"
+	"go1f/pkg/taskql"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Коды ошибок в поле "code" ответа. В отличие от текста сообщения
+// они не зависят от языка и не меняются.
+const (
+	CodeInvalidRequest       = "invalid_request"
+	CodeMissingParam         = "missing_param"
+	CodeInvalidParam         = "invalid_param"
+	CodeInvalidID            = "invalid_id"
+	CodeInvalidDate          = "invalid_date"
+	CodeInvalidTime          = "invalid_time"
+	CodeInvalidTimezone      = "invalid_timezone"
+	CodeInvalidRule          = "invalid_rule"
+	CodeNoDate               = "no_date"
+	CodeSeriesEnded          = "series_ended"
+	CodeEmptyTitle           = "empty_title"
+	CodeSkipNotAllowed       = "skip_not_allowed"
+	CodeInvalidQuery         = "invalid_query"
+	CodeInvalidCalendar      = "invalid_calendar"
+	CodeNotFound             = "not_found"
+	CodeConflict             = "conflict"
+	CodePreconditionFailed   = "precondition_failed"
+	CodePreconditionRequired = "precondition_required"
+	CodeWrongPassword        = "wrong_password"
+	CodeUnauthorized         = "unauthorized"
+	CodeInvalidToken         = "invalid_token"
+	CodeMethodNotAllowed     = "method_not_allowed"
+	CodeTimeout              = "timeout"
+	CodeCanceled             = "canceled"
+	CodeInternal             = "internal"
+)
+
+// Сообщения об ошибках по кодам: русские и английские
+var errorMessages = map[string][2]string{
+	CodeInvalidRequest:       {"Некорректный запрос", "Malformed request"},
+	CodeMissingParam:         {"Не указан обязательный параметр", "Required parameter is missing"},
+	CodeInvalidParam:         {"Некорректное значение параметра", "Invalid parameter value"},
+	CodeInvalidID:            {"Некорректный ID задачи", "Invalid task ID"},
+	CodeInvalidDate:          {"Некорректный формат даты", "Invalid date"},
+	CodeInvalidTime:          {"Некорректный формат времени", "Invalid time"},
+	CodeInvalidTimezone:      {"Некорректный часовой пояс", "Unknown time zone"},
+	CodeInvalidRule:          {"Некорректное правило повторения", "Invalid repeat rule"},
+	CodeNoDate:               {"Правило не даёт ни одной подходящей даты", "The repeat rule yields no dates"},
+	CodeSeriesEnded:          {"Серия повторений уже завершена", "The repeat series has already ended"},
+	CodeEmptyTitle:           {"Не указан заголовок задачи", "Task title is required"},
+	CodeSkipNotAllowed:       {"Эту задачу нельзя пропустить", "This task cannot be skipped"},
+	CodeInvalidQuery:         {"Ошибка в запросе", "Invalid query"},
+	CodeInvalidCalendar:      {"Некорректный файл календаря", "Invalid calendar file"},
+	CodeNotFound:             {"Не найдено", "Not found"},
+	CodeConflict:             {"Задача изменена другим запросом, повторите действие", "The task was changed by another request, try again"},
+	CodePreconditionFailed:   {"Задача изменилась после загрузки, обновите её", "The task has changed since it was loaded, reload it"},
+	CodePreconditionRequired: {"Требуется заголовок If-Match с версией задачи", "If-Match header with the task version is required"},
+	CodeWrongPassword:        {"Неверный пароль", "Wrong password"},
+	CodeUnauthorized:         {"Требуется аутентификация", "Authentication required"},
+	CodeInvalidToken:         {"Неверный токен", "Invalid token"},
+	CodeMethodNotAllowed:     {"Метод не поддерживается", "Method not allowed"},
+	CodeTimeout:              {"Превышено время ожидания запроса", "Request timed out"},
+	CodeCanceled:             {"Запрос отменён клиентом", "Request canceled by client"},
+	CodeInternal:             {"Внутренняя ошибка сервера", "Internal server error"},
+}
+
+// apiError — ошибка запроса с кодом и параметром, к которому она относится
+type apiError struct {
+	Code  string
+	Field string
+	Err   error // исходная ошибка, её текст передаётся в поле "detail"
+}
+
+func (e *apiError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return errorMessages[e.Code][0]
+}
+
+func (e *apiError) Unwrap() error {
+	return e.Err
+}
+
+func newError(code, field string, err error) *apiError {
+	return &apiError{Code: code, Field: field, Err: err}
+}
+
+// ErrorResp — тело ответа с ошибкой
+type ErrorResp struct {
+	Error    string `json:"error"`              // сообщение на языке запроса
+	Code     string `json:"code"`               // код ошибки
+	Field    string `json:"field,omitempty"`    // параметр запроса с ошибкой
+	Detail   string `json:"detail,omitempty"`   // подробности, если они есть
+	Position int    `json:"position,omitempty"` // позиция ошибки в запросе taskql, с единицы
+}
+
+// classifyError определяет код ошибки пакетов dateutil и db.
+// Неизвестные ошибки считаются внутренними.
+func classifyError(err error) *apiError {
+	var (
+		e         *apiError
+		syntaxErr *taskql.SyntaxError
+	)
+	switch {
+	case errors.As(err, &e):
+		return e
+	case errors.Is(err, dateutil.ErrInvalidRule):
+		return newError(CodeInvalidRule, "repeat", err)
+	case errors.Is(err, dateutil.ErrNoDate):
+		return newError(CodeNoDate, "repeat", err)
+	case errors.Is(err, dateutil.ErrSeriesEnded):
+		return newError(CodeSeriesEnded, "repeat", err)
+	case errors.Is(err, dateutil.ErrInvalidDate):
+		return newError(CodeInvalidDate, "date", err)
+	case errors.Is(err, dateutil.ErrInvalidCalendar):
+		return newError(CodeInvalidCalendar, "file", err)
+	case errors.Is(err, db.ErrNotFound):
+		return newError(CodeNotFound, "id", err)
+	case errors.Is(err, db.ErrInvalidID):
+		return newError(CodeInvalidID, "id", err)
+	case errors.Is(err, db.ErrConflict):
+		return newError(CodeConflict, "id", err)
+	case errors.Is(err, db.ErrInvalidCursor):
+		return newError(CodeInvalidParam, "cursor", err)
+	case errors.Is(err, db.ErrInvalidSort):
+		return newError(CodeInvalidParam, "sort", err)
+	case errors.Is(err, db.ErrTagNotFound):
+		return newError(CodeNotFound, "id", err)
+	case errors.Is(err, db.ErrTagExists):
+		return newError(CodeConflict, "name", err)
+	case errors.Is(err, db.ErrFilterNotFound):
+		return newError(CodeNotFound, "name", err)
+	case errors.Is(err, db.ErrFilterExists):
+		return newError(CodeConflict, "name", err)
+	case errors.As(err, &syntaxErr):
+		return newError(CodeInvalidQuery, "query", err)
+	case errors.Is(err, db.ErrHolidayNotFound):
+		return newError(CodeNotFound, "date", err)
+	case errors.Is(err, context.DeadlineExceeded):
+		return newError(CodeTimeout, "", err)
+	case errors.Is(err, context.Canceled):
+		return newError(CodeCanceled, "", err)
+	}
+	return newError(CodeInternal, "", err)
+}
+
+// errorMessage возвращает сообщение для кода ошибки на языке locale
+func errorMessage(code, locale string) string {
+	lang := 0
+	if strings.HasPrefix(strings.ToLower(locale), "en") {
+		lang = 1
+	}
+	return errorMessages[code][lang]
+}
+
+// statusClientClosedRequest — нестандартный статус 499 (как в nginx):
+// клиент закрыл соединение, не дождавшись ответа
+const statusClientClosedRequest = 499
+
+// errorStatus возвращает HTTP-статус для кода ошибки: 404 — не найдено,
+// 409 — конфликт параллельных изменений, 412 и 428 — не выполнено
+// или не указано условие If-Match, 504 — истекло время запроса
+// к базе, 400 — ошибка в запросе
+func errorStatus(code string) int {
+	switch code {
+	case CodeNotFound:
+		return http.StatusNotFound
+	case CodeConflict:
+		return http.StatusConflict
+	case CodePreconditionFailed:
+		return http.StatusPreconditionFailed
+	case CodePreconditionRequired:
+		return http.StatusPreconditionRequired
+	case CodeMethodNotAllowed:
+		return http.StatusMethodNotAllowed
+	case CodeWrongPassword, CodeUnauthorized, CodeInvalidToken:
+		return http.StatusUnauthorized
+	case CodeTimeout:
+		return http.StatusGatewayTimeout
+	case CodeCanceled:
+		return statusClientClosedRequest
+	case CodeInternal:
+		return http.StatusInternalServerError
+	}
+	return http.StatusBadRequest
+}
+
+// writeError отправляет ошибку в виде ErrorResp. Ошибки, созданные
+// не через newError, получают код по виду ошибки, а статус ответа
+// всегда определяется кодом.
+func (a *API) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	e := classifyError(err)
+	resp := ErrorResp{
+		Error: errorMessage(e.Code, a.locale(r)),
+		Code:  e.Code,
+		Field: e.Field,
+	}
+	// Текст внутренней ошибки может раскрыть устройство базы, поэтому он
+	// только пишется в журнал
+	switch {
+	case e.Code == CodeInternal:
+		log.Printf("Внутренняя ошибка %s %s: %v", r.Method, r.URL.Path, e.Err)
+	case e.Err != nil && e.Err.Error() != resp.Error:
+		resp.Detail = e.Err.Error()
+	}
+	var syntaxErr *taskql.SyntaxError
+	if errors.As(e.Err, &syntaxErr) {
+		resp.Position = syntaxErr.Pos
+	}
+	a.writeJSON(w, r, errorStatus(e.Code), resp)
+}
@@ -2,12 +2,21 @@ package config
 
 import (
 	"errors"
+	"fmt"
+	"go1f/pkg/db"
 	"os"
+	"strconv"
+	"time"
 )
 
 type Config struct {
 	Port     string
 	Password string
+	Location *time.Location // часовой пояс, в котором считается "сегодня"
+	// ограничение времени одного запроса к базе, 0 — без ограничения
+	QueryTimeout time.Duration
+	// изменение задачи без заголовка If-Match отклоняется
+	RequireIfMatch bool
 }
 
 func Load() (*Config, error) {
@@ -21,8 +30,38 @@ func Load() (*Config, error) {
 		port = "7540"
 	}
 
+	location := time.Local
+	if tz := os.Getenv("TODO_TZ"); tz != "" {
+		var err error
+		location, err = time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("некорректный часовой пояс TODO_TZ: %v", err)
+		}
+	}
+
+	queryTimeout := db.DefaultQueryTimeout
+	if v := os.Getenv("TODO_QUERY_TIMEOUT"); v != "" {
+		var err error
+		queryTimeout, err = time.ParseDuration(v)
+		if err != nil || queryTimeout < 0 {
+			return nil, fmt.Errorf("некорректное время ожидания TODO_QUERY_TIMEOUT: %q", v)
+		}
+	}
+
+	requireIfMatch := false
+	if v := os.Getenv("TODO_REQUIRE_IF_MATCH"); v != "" {
+		var err error
+		requireIfMatch, err = strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("некорректное значение TODO_REQUIRE_IF_MATCH: %q", v)
+		}
+	}
+
 	return &Config{
-		Port:     port,
-		Password: password,
+		Port:           port,
+		Password:       password,
+		Location:       location,
+		QueryTimeout:   queryTimeout,
+		RequireIfMatch: requireIfMatch,
 	}, nil
 }
@@ -0,0 +1,109 @@
+package dateutil
+
+import (
+	"strconv"
+	"time"
+)
+
+// Сколько дней подряд может не быть ни одного рабочего дня, прежде чем
+// календарь считается ошибочным
+const maxIdleDays = 366
+
+// BusinessRule — правило "b N": каждый N-й рабочий день, считая от даты начала.
+// Рабочие дни определяются производственным календарём Calendar (см. WithCalendar).
+type BusinessRule struct {
+	Days     int
+	Calendar *Calendar
+}
+
+// Shift — направление переноса даты, выпавшей на нерабочий день
+type Shift int
+
+const (
+	ShiftNone Shift = iota
+	ShiftNext       // на следующий рабочий день
+	ShiftPrev       // на предыдущий рабочий день
+)
+
+// Shifted — правило, даты которого переносятся с выходных и праздников
+// на ближайший рабочий день: "m 1 shift next", "m -1 shift prev"
+type Shifted struct {
+	Rule     Rule
+	Shift    Shift
+	Calendar *Calendar
+}
+
+func parseBusinessRule(rule string, parts []string) (Rule, error) {
+	if len(parts) != 2 {
+		return nil, ruleError(rule, "b", "неверный формат правила 'b'")
+	}
+
+	days, err := strconv.Atoi(parts[1])
+	if err != nil || days < 1 || days > 400 {
+		return nil, ruleError(rule, "days", "некорректное количество рабочих дней")
+	}
+	return BusinessRule{Days: days}, nil
+}
+
+// Правило "b": ближайший рабочий день с номером, кратным N, позже now
+func (r BusinessRule) Next(now, startDate time.Time) (time.Time, error) {
+	cal := r.Calendar
+
+	// Сразу перескакиваем к now, посчитав рабочие дни между ним и датой начала
+	date, passed := startDate, 0
+	if today := dayOf(now, startDate.Location()); today.After(startDate) {
+		date, passed = today, cal.workdaysBetween(startDate, today)
+	}
+
+	need := r.Days - passed%r.Days
+	for idle := 0; idle < maxIdleDays; {
+		date = date.AddDate(0, 0, 1)
+		if !cal.IsWorkday(date) {
+			idle++
+			continue
+		}
+		idle = 0
+		if need--; need == 0 {
+			return date, nil
+		}
+	}
+	return time.Time{}, ErrNoDate
+}
+
+func (r BusinessRule) String() string {
+	return "b " + strconv.Itoa(r.Days)
+}
+
+// Next возвращает ближайшую дату вложенного правила, перенесённую с нерабочего
+// дня. Перенесённая дата тоже должна быть позже now и даты начала.
+func (s Shifted) Next(now, startDate time.Time) (time.Time, error) {
+	cal := s.Calendar
+
+	after := now
+	for i := 0; i < maxIdleDays; i++ {
+		date, err := s.Rule.Next(after, startDate)
+		if err != nil {
+			return date, err
+		}
+
+		shifted, err := cal.shift(date, s.Shift)
+		if err != nil {
+			return shifted, err
+		}
+		if shifted.After(startDate) && afterNow(shifted, now) {
+			return shifted, nil
+		}
+		after = date
+	}
+	return time.Time{}, ErrNoDate
+}
+
+func (s Shifted) String() string {
+	switch s.Shift {
+	case ShiftNext:
+		return s.Rule.String() + " shift next"
+	case ShiftPrev:
+		return s.Rule.String() + " shift prev"
+	}
+	return s.Rule.String()
+}
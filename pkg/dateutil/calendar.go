@@ -0,0 +1,326 @@
+package dateutil
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Holiday — день производственного календаря, отличающийся от обычного:
+// праздник в будни или рабочий день в выходные (Workday)
+type Holiday struct {
+	Date    time.Time
+	Title   string
+	Workday bool
+}
+
+// Calendar — производственный календарь. Без исключений, как и в пустом
+// (nil) календаре, рабочими считаются дни с понедельника по пятницу.
+type Calendar struct {
+	days map[string]Holiday
+}
+
+// NewCalendar создаёт календарь с указанными праздниками и переносами
+func NewCalendar(holidays []Holiday) *Calendar {
+	c := &Calendar{days: make(map[string]Holiday, len(holidays))}
+	for _, h := range holidays {
+		c.days[h.Date.Format(DateFormat)] = h
+	}
+	return c
+}
+
+// IsWorkday проверяет, что date — рабочий день
+func (c *Calendar) IsWorkday(date time.Time) bool {
+	if c == nil {
+		return !isWeekend(date)
+	}
+	if h, ok := c.days[date.Format(DateFormat)]; ok {
+		return h.Workday
+	}
+	return !isWeekend(date)
+}
+
+// WithCalendar возвращает правило, в котором правила "b" и "shift"
+// определяют рабочие дни по календарю cal
+func WithCalendar(rule Rule, cal *Calendar) Rule {
+	switch r := rule.(type) {
+	case BusinessRule:
+		r.Calendar = cal
+		return r
+	case Shifted:
+		r.Calendar = cal
+		return r
+	case Bounded:
+		r.Rule = WithCalendar(r.Rule, cal)
+		return r
+	case FromDone:
+		r.Rule = WithCalendar(r.Rule, cal)
+		return r
+	case Except:
+		r.Rule = WithCalendar(r.Rule, cal)
+		return r
+	}
+	return rule
+}
+
+// workdaysBetween возвращает число рабочих дней в интервале (from, to]
+func (c *Calendar) workdaysBetween(from, to time.Time) int {
+	days := daysBetween(from, to)
+	if days <= 0 {
+		return 0
+	}
+
+	// Будни в полных неделях считаются сразу, остаток — по дням
+	count := days / 7 * 5
+	for d := from.AddDate(0, 0, days/7*7+1); !d.After(to); d = d.AddDate(0, 0, 1) {
+		if !isWeekend(d) {
+			count++
+		}
+	}
+
+	if c == nil {
+		return count
+	}
+	fromKey, toKey := from.Format(DateFormat), to.Format(DateFormat)
+	for key, h := range c.days {
+		if key <= fromKey || key > toKey || h.Workday == !isWeekend(h.Date) {
+			continue
+		}
+		if h.Workday {
+			count++
+		} else {
+			count--
+		}
+	}
+	return count
+}
+
+// shift переносит date на ближайший рабочий день в направлении dir
+func (c *Calendar) shift(date time.Time, dir Shift) (time.Time, error) {
+	step := 1
+	if dir == ShiftPrev {
+		step = -1
+	}
+	for i := 0; i < maxIdleDays; i++ {
+		if c.IsWorkday(date) {
+			return date, nil
+		}
+		date = date.AddDate(0, 0, step)
+	}
+	return time.Time{}, ErrNoDate
+}
+
+func isWeekend(date time.Time) bool {
+	wd := date.Weekday()
+	return wd == time.Saturday || wd == time.Sunday
+}
+
+// ParseCalendar разбирает календарь в формате iCalendar (.ics) или CSV.
+// CSV содержит строки "дата[,название[,тип]]", где тип "workday" или
+// "рабочий" отмечает рабочий выходной, либо совпадает с форматом
+// производственного календаря с data.gov.ru ("Год/Месяц,Январь,...").
+func ParseCalendar(data []byte) ([]Holiday, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	parse := parseCSV
+	if bytes.Contains(data, []byte("BEGIN:VCALENDAR")) {
+		parse = parseICS
+	}
+	holidays, err := parse(data)
+	if err != nil {
+		return nil, &kindError{ErrInvalidCalendar, err.Error()}
+	}
+	return holidays, nil
+}
+
+// parseICS извлекает из календаря события на целый день: каждый день
+// события считается праздником
+func parseICS(data []byte) ([]Holiday, error) {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		// Длинные строки переносятся с отступом в начале продолжения
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var holidays []Holiday
+	var start, end time.Time
+	var title string
+	inEvent := false
+	for _, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		// Параметры свойства вроде DTSTART;VALUE=DATE не нужны
+		name, _, _ = strings.Cut(strings.ToUpper(name), ";")
+
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			inEvent, start, end, title = true, time.Time{}, time.Time{}, ""
+		case name == "END" && value == "VEVENT":
+			inEvent = false
+			if start.IsZero() {
+				return nil, errors.New("у события календаря нет даты начала")
+			}
+			if !end.After(start) {
+				end = start.AddDate(0, 0, 1)
+			}
+			for d := start; d.Before(end) && len(holidays) <= maxHolidays; d = d.AddDate(0, 0, 1) {
+				holidays = append(holidays, Holiday{Date: d, Title: title})
+			}
+		case !inEvent:
+		case name == "DTSTART" || name == "DTEND":
+			if len(value) < len(DateFormat) {
+				return nil, fmt.Errorf("некорректная дата %q в календаре", value)
+			}
+			date, err := time.Parse(DateFormat, value[:len(DateFormat)])
+			if err != nil {
+				return nil, fmt.Errorf("некорректная дата %q в календаре", value)
+			}
+			if name == "DTSTART" {
+				start = date
+			} else {
+				end = date
+			}
+		case name == "SUMMARY":
+			title = unescapeICS(value)
+		}
+	}
+
+	if len(holidays) > maxHolidays {
+		return nil, errTooManyHolidays
+	}
+	return holidays, nil
+}
+
+func unescapeICS(value string) string {
+	return strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(value)
+}
+
+// Ограничение на размер календаря, чтобы случайно загруженный
+// многолетний повторяющийся файл не раздул таблицу
+const maxHolidays = 10000
+
+var errTooManyHolidays = fmt.Errorf("в календаре больше %d дней", maxHolidays)
+
+var csvDateFormats = []string{DateFormat, "2006-01-02", "02.01.2006"}
+
+func parseCSV(data []byte) ([]Holiday, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	firstLine, _, _ := bytes.Cut(data, []byte("\n"))
+	if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		reader.Comma = ';'
+	}
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("некорректный CSV: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, errors.New("календарь пуст")
+	}
+	if strings.HasPrefix(records[0][0], "Год") {
+		return parseProductionCalendar(records[1:])
+	}
+
+	var holidays []Holiday
+	for i, record := range records {
+		date, ok := parseCSVDate(record[0])
+		if !ok {
+			// Первая строка может быть заголовком
+			if i == 0 {
+				continue
+			}
+			return nil, fmt.Errorf("строка %d: некорректная дата %q", i+1, record[0])
+		}
+
+		h := Holiday{Date: date}
+		if len(record) > 1 {
+			h.Title = strings.TrimSpace(record[1])
+		}
+		if len(record) > 2 {
+			switch strings.ToLower(strings.TrimSpace(record[2])) {
+			case "", "holiday", "праздник", "выходной", "0":
+			case "workday", "рабочий", "1":
+				h.Workday = true
+			default:
+				return nil, fmt.Errorf("строка %d: неизвестный тип дня %q", i+1, record[2])
+			}
+		}
+		holidays = append(holidays, h)
+	}
+
+	if len(holidays) > maxHolidays {
+		return nil, errTooManyHolidays
+	}
+	return holidays, nil
+}
+
+func parseCSVDate(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	for _, layout := range csvDateFormats {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseProductionCalendar разбирает строки производственного календаря:
+// год и двенадцать столбцов со списками нерабочих дней месяца. Дни со
+// звёздочкой — сокращённые рабочие, с плюсом — перенесённые выходные.
+// Все остальные дни года рабочие, поэтому не попавшие в список субботы
+// и воскресенья становятся рабочими днями.
+func parseProductionCalendar(records [][]string) ([]Holiday, error) {
+	var holidays []Holiday
+	for i, record := range records {
+		year, err := strconv.Atoi(strings.TrimSpace(record[0]))
+		if err != nil || year < 1 || year > 9999 || len(record) < 13 {
+			return nil, fmt.Errorf("строка %d: некорректная строка производственного календаря", i+2)
+		}
+
+		for month := time.January; month <= time.December; month++ {
+			off := make(map[int]bool)
+			for _, item := range strings.Split(record[month], ",") {
+				item = strings.TrimSpace(item)
+				if item == "" || strings.HasSuffix(item, "*") {
+					continue
+				}
+				day, err := strconv.Atoi(strings.TrimSuffix(item, "+"))
+				if err != nil || day < 1 || day > lastDayOfMonth(year, month) {
+					return nil, fmt.Errorf("строка %d: некорректный день %q", i+2, item)
+				}
+				off[day] = true
+			}
+
+			for day := 1; day <= lastDayOfMonth(year, month); day++ {
+				date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+				switch {
+				case off[day] && !isWeekend(date):
+					holidays = append(holidays, Holiday{Date: date, Title: "Нерабочий день"})
+				case !off[day] && isWeekend(date):
+					holidays = append(holidays, Holiday{Date: date, Title: "Рабочий день", Workday: true})
+				}
+			}
+		}
+		if len(holidays) > maxHolidays {
+			return nil, errTooManyHolidays
+		}
+	}
+	return holidays, nil
+}
@@ -0,0 +1,195 @@
+package dateutil
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Единицы, в которых задаётся шаг повторения
+type unit int
+
+const (
+	unitMinute unit = iota
+	unitHour
+	unitDay
+	unitWeek
+	unitMonth
+	unitYear
+)
+
+// phrasebook — словарь одного языка для Describe
+type phrasebook interface {
+	every(u unit, n int) string
+	businessDays(n int) string
+	// weekdays описывает повторение по дням недели раз в interval недель;
+	// нулевой interval — дни недели внутри другого периода
+	weekdays(days []time.Weekday, interval int) string
+	monthDay(day int) string
+	nthWeekday(n int, day time.Weekday) string
+	monthly(items []string, numeric bool, months []int, interval int) string
+	on(items []string, numeric bool) string
+	inMonths(months []int) string
+	setPos(pos []int) string
+	until(date time.Time) string
+	count(n int) string
+	shift(s Shift) string
+	fromDone() string
+	join(items []string) string
+}
+
+// Describe возвращает описание правила повторения словами на языке locale
+// ("ru" или "en", по умолчанию русский): "m -1,15 3,6,9" —
+// "15-го и в последний день марта, июня и сентября"
+func Describe(rule Rule, locale string) string {
+	var pb phrasebook = russian{}
+	if strings.HasPrefix(strings.ToLower(locale), "en") {
+		pb = english{}
+	}
+	return describe(pb, rule)
+}
+
+func describe(pb phrasebook, rule Rule) string {
+	switch r := rule.(type) {
+	case DailyRule:
+		return pb.every(unitDay, r.Days)
+	case HourlyRule:
+		return pb.every(unitHour, r.Hours)
+	case MinuteRule:
+		return pb.every(unitMinute, r.Minutes)
+	case YearlyRule:
+		return pb.every(unitYear, 1)
+	case BusinessRule:
+		return pb.businessDays(r.Days)
+	case WeeklyRule:
+		days := make([]time.Weekday, 0, len(r.Days))
+		for _, d := range r.Days {
+			days = append(days, time.Weekday(d%7))
+		}
+		return pb.weekdays(sortWeekdays(days), max(r.Interval, 1))
+	case MonthlyRule:
+		return pb.monthly(monthDayItems(pb, r.Days), allPositive(r.Days), r.Months, max(r.Interval, 1))
+	case MonthWeekdayRule:
+		days := append([]NthWeekday(nil), r.Days...)
+		sort.Slice(days, func(i, j int) bool {
+			if (days[i].N > 0) != (days[j].N > 0) {
+				return days[i].N > 0
+			}
+			if days[i].N != days[j].N {
+				return days[i].N < days[j].N
+			}
+			return days[i].Day < days[j].Day
+		})
+
+		items := make([]string, 0, len(days))
+		for _, wd := range days {
+			items = append(items, pb.nthWeekday(wd.N, time.Weekday(wd.Day%7)))
+		}
+		return pb.monthly(items, false, r.Months, max(r.Interval, 1))
+	case *RRule:
+		return describeRRule(pb, r)
+	case Bounded:
+		parts := []string{describe(pb, r.Rule)}
+		if !r.Until.IsZero() {
+			parts = append(parts, pb.until(r.Until))
+		}
+		if r.Count > 0 {
+			parts = append(parts, pb.count(r.Count))
+		}
+		return strings.Join(parts, ", ")
+	case Shifted:
+		return describe(pb, r.Rule) + ", " + pb.shift(r.Shift)
+	case FromDone:
+		return describe(pb, r.Rule) + ", " + pb.fromDone()
+	case Except:
+		return describe(pb, r.Rule)
+	}
+	return rule.String()
+}
+
+func describeRRule(pb phrasebook, r *RRule) string {
+	units := map[string]unit{"DAILY": unitDay, "WEEKLY": unitWeek, "MONTHLY": unitMonth, "YEARLY": unitYear}
+
+	var plain []time.Weekday
+	var items []string
+	for _, wd := range r.ByDay {
+		if wd.N == 0 {
+			plain = append(plain, wd.Day)
+		} else {
+			items = append(items, pb.nthWeekday(wd.N, wd.Day))
+		}
+	}
+
+	var parts []string
+	if r.Freq == "WEEKLY" && len(plain) > 0 {
+		parts = append(parts, pb.weekdays(sortWeekdays(plain), r.Interval))
+	} else {
+		parts = append(parts, pb.every(units[r.Freq], r.Interval))
+		if len(plain) > 0 {
+			parts = append(parts, pb.weekdays(sortWeekdays(plain), 0))
+		}
+	}
+	if len(r.ByMonthDay) > 0 {
+		parts = append(parts, pb.on(monthDayItems(pb, r.ByMonthDay), allPositive(r.ByMonthDay)))
+	}
+	if len(items) > 0 {
+		parts = append(parts, pb.on(items, false))
+	}
+	if len(r.ByMonth) > 0 {
+		parts = append(parts, pb.inMonths(r.ByMonth))
+	}
+	if len(r.BySetPos) > 0 {
+		parts = append(parts, pb.setPos(r.BySetPos))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, pb.until(r.Until))
+	}
+	if r.Count > 0 {
+		parts = append(parts, pb.count(r.Count))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// monthDayItems описывает дни месяца: сначала числа по возрастанию,
+// затем дни с конца месяца
+func monthDayItems(pb phrasebook, days []int) []string {
+	sorted := append([]int(nil), days...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if (sorted[i] > 0) != (sorted[j] > 0) {
+			return sorted[i] > 0
+		}
+		return sorted[i] < sorted[j]
+	})
+
+	items := make([]string, 0, len(sorted))
+	for _, d := range sorted {
+		items = append(items, pb.monthDay(d))
+	}
+	return items
+}
+
+// sortWeekdays упорядочивает дни недели с понедельника
+func sortWeekdays(days []time.Weekday) []time.Weekday {
+	sorted := append([]time.Weekday(nil), days...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return (sorted[i]+6)%7 < (sorted[j]+6)%7
+	})
+	return sorted
+}
+
+func allPositive(days []int) bool {
+	for _, d := range days {
+		if d < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// joinWords соединяет перечисление через запятую, последний элемент — через conj
+func joinWords(items []string, conj string) string {
+	if len(items) < 2 {
+		return strings.Join(items, "")
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " " + conj + " " + items[len(items)-1]
+}
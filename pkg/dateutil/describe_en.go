@@ -0,0 +1,150 @@
+package dateutil
+
+import (
+	"fmt"
+	"time"
+)
+
+type english struct{}
+
+var enUnits = map[unit]string{
+	unitMinute: "minute",
+	unitHour:   "hour",
+	unitDay:    "day",
+	unitWeek:   "week",
+	unitMonth:  "month",
+	unitYear:   "year",
+}
+
+var enOrdinals = map[int]string{1: "first", 2: "second", 3: "third", 4: "fourth", 5: "fifth", -1: "last"}
+
+// enOrdinal возвращает порядковое числительное: first, 21st, second to last
+func enOrdinal(n int) string {
+	if word, ok := enOrdinals[n]; ok {
+		return word
+	}
+	if n < 0 {
+		return enOrdinal(-n) + " to last"
+	}
+	return enNumeral(n)
+}
+
+// enNumeral записывает порядковое числительное цифрами: 1st, 2nd, 15th
+func enNumeral(n int) string {
+	suffix := "th"
+	if n%100 < 11 || n%100 > 13 {
+		switch n % 10 {
+		case 1:
+			suffix = "st"
+		case 2:
+			suffix = "nd"
+		case 3:
+			suffix = "rd"
+		}
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
+
+func (english) every(u unit, n int) string {
+	if n == 1 {
+		return "every " + enUnits[u]
+	}
+	return fmt.Sprintf("every %d %ss", n, enUnits[u])
+}
+
+func (english) businessDays(n int) string {
+	if n == 1 {
+		return "every business day"
+	}
+	return "every " + enNumeral(n) + " business day"
+}
+
+func (en english) weekdays(days []time.Weekday, interval int) string {
+	names := make([]string, 0, len(days))
+	for _, d := range days {
+		names = append(names, d.String())
+	}
+	switch {
+	case interval > 1:
+		return en.every(unitWeek, interval) + " on " + en.join(names)
+	case interval == 0:
+		return "on " + en.join(names)
+	}
+	return "every " + en.join(names)
+}
+
+func (english) monthDay(day int) string {
+	if day > 0 {
+		return "the " + enNumeral(day)
+	}
+	return "the " + enOrdinal(day) + " day"
+}
+
+func (english) nthWeekday(n int, day time.Weekday) string {
+	return "the " + enOrdinal(n) + " " + day.String()
+}
+
+func (en english) monthly(items []string, numeric bool, months []int, interval int) string {
+	s := "on " + en.join(items) + " of "
+	if len(months) > 0 {
+		names := make([]string, 0, len(months))
+		for _, m := range months {
+			names = append(names, time.Month(m).String())
+		}
+		s += en.join(names)
+		if interval > 1 {
+			s += ", " + en.every(unitMonth, interval)
+		}
+		return s
+	}
+	return s + en.every(unitMonth, interval)
+}
+
+func (en english) on(items []string, numeric bool) string {
+	return "on " + en.join(items)
+}
+
+func (en english) inMonths(months []int) string {
+	names := make([]string, 0, len(months))
+	for _, m := range months {
+		names = append(names, time.Month(m).String())
+	}
+	return "in " + en.join(names)
+}
+
+func (en english) setPos(pos []int) string {
+	words := make([]string, 0, len(pos))
+	for _, p := range pos {
+		words = append(words, enOrdinal(p))
+	}
+	return "the " + en.join(words) + " of these days"
+}
+
+func (english) until(date time.Time) string {
+	return "until " + date.Format("January 2, 2006")
+}
+
+func (english) count(n int) string {
+	switch n {
+	case 1:
+		return "once"
+	case 2:
+		return "twice"
+	}
+	return fmt.Sprintf("%d times", n)
+}
+
+func (english) shift(s Shift) string {
+	if s == ShiftPrev {
+		return "moved to the previous business day"
+	}
+	return "moved to the next business day"
+}
+
+func (english) fromDone() string {
+	return "counted from completion"
+}
+
+func (english) join(items []string) string {
+	return joinWords(items, "and")
+}
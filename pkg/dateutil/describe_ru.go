@@ -0,0 +1,195 @@
+package dateutil
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type russian struct{}
+
+// Формы единиц: "каждый день", "каждые 2 дня", "каждые 5 дней"
+var ruUnits = map[unit]struct {
+	forms    [3]string
+	feminine bool
+}{
+	unitMinute: {[3]string{"минуту", "минуты", "минут"}, true},
+	unitHour:   {[3]string{"час", "часа", "часов"}, false},
+	unitDay:    {[3]string{"день", "дня", "дней"}, false},
+	unitWeek:   {[3]string{"неделю", "недели", "недель"}, true},
+	unitMonth:  {[3]string{"месяц", "месяца", "месяцев"}, false},
+	unitYear:   {[3]string{"год", "года", "лет"}, false},
+}
+
+var ruMonthsGenitive = [...]string{"января", "февраля", "марта", "апреля", "мая", "июня",
+	"июля", "августа", "сентября", "октября", "ноября", "декабря"}
+
+var ruMonthsPrepositional = [...]string{"январе", "феврале", "марте", "апреле", "мае", "июне",
+	"июле", "августе", "сентябре", "октябре", "ноябре", "декабре"}
+
+// Дни недели по индексу time.Weekday: "по понедельникам" и "в понедельник"
+var ruWeekdaysDative = [...]string{"воскресеньям", "понедельникам", "вторникам", "средам",
+	"четвергам", "пятницам", "субботам"}
+
+var ruWeekdaysAccusative = [...]string{"воскресенье", "понедельник", "вторник", "среду",
+	"четверг", "пятницу", "субботу"}
+
+// Род дня недели: 0 — мужской, 1 — женский, 2 — средний
+var ruWeekdayGender = [...]int{2, 0, 0, 1, 0, 1, 1}
+
+var ruOrdinals = map[int][3]string{
+	1:  {"первый", "первую", "первое"},
+	2:  {"второй", "вторую", "второе"},
+	3:  {"третий", "третью", "третье"},
+	4:  {"четвёртый", "четвёртую", "четвёртое"},
+	5:  {"пятый", "пятую", "пятое"},
+	-1: {"последний", "последнюю", "последнее"},
+	-2: {"предпоследний", "предпоследнюю", "предпоследнее"},
+}
+
+// ruPlural выбирает форму слова для числа n: 1 день, 2 дня, 5 дней
+func ruPlural(n int, forms [3]string) string {
+	switch {
+	case n%10 == 1 && n%100 != 11:
+		return forms[0]
+	case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+		return forms[1]
+	}
+	return forms[2]
+}
+
+// ruOrdinal возвращает порядковое числительное в роде gender
+func ruOrdinal(n, gender int) string {
+	if words, ok := ruOrdinals[n]; ok {
+		return words[gender]
+	}
+	suffix := [3]string{"-й", "-ю", "-е"}[gender]
+	if n < 0 {
+		return fmt.Sprintf("%d%s с конца", -n, suffix)
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
+
+func (russian) every(u unit, n int) string {
+	info := ruUnits[u]
+	each := "каждый"
+	if info.feminine {
+		each = "каждую"
+	}
+	switch {
+	case n == 1:
+		return each + " " + info.forms[0]
+	case n%10 == 1 && n%100 != 11:
+		return fmt.Sprintf("%s %d %s", each, n, info.forms[0])
+	}
+	return fmt.Sprintf("каждые %d %s", n, ruPlural(n, info.forms))
+}
+
+func (russian) businessDays(n int) string {
+	if n == 1 {
+		return "каждый рабочий день"
+	}
+	return fmt.Sprintf("каждый %d-й рабочий день", n)
+}
+
+func (ru russian) weekdays(days []time.Weekday, interval int) string {
+	names := make([]string, 0, len(days))
+	for _, d := range days {
+		names = append(names, ruWeekdaysDative[d])
+	}
+	s := "по " + ru.join(names)
+	if interval > 1 {
+		s = ru.every(unitWeek, interval) + " " + s
+	}
+	return s
+}
+
+func (russian) monthDay(day int) string {
+	switch {
+	case day > 0:
+		return fmt.Sprintf("%d-го", day)
+	case day == -1:
+		return "в последний день"
+	case day == -2:
+		return "в предпоследний день"
+	}
+	return fmt.Sprintf("в %d-й с конца день", -day)
+}
+
+func (russian) nthWeekday(n int, day time.Weekday) string {
+	ordinal := ruOrdinal(n, ruWeekdayGender[day])
+	prep := "в"
+	if strings.HasPrefix(ordinal, "втор") {
+		prep = "во"
+	}
+	return prep + " " + ordinal + " " + ruWeekdaysAccusative[day]
+}
+
+func (ru russian) monthly(items []string, numeric bool, months []int, interval int) string {
+	s := ru.join(items)
+	if len(months) > 0 {
+		names := make([]string, 0, len(months))
+		for _, m := range months {
+			names = append(names, ruMonthsGenitive[m-1])
+		}
+		s += " " + ru.join(names)
+	} else {
+		if numeric {
+			s += " числа"
+		}
+		if interval == 1 {
+			return s + " каждого месяца"
+		}
+	}
+	if interval > 1 {
+		s += ", " + ru.every(unitMonth, interval)
+	}
+	return s
+}
+
+func (ru russian) on(items []string, numeric bool) string {
+	s := ru.join(items)
+	if numeric {
+		s += " числа"
+	}
+	return s
+}
+
+func (ru russian) inMonths(months []int) string {
+	names := make([]string, 0, len(months))
+	for _, m := range months {
+		names = append(names, ruMonthsPrepositional[m-1])
+	}
+	return "в " + ru.join(names)
+}
+
+func (ru russian) setPos(pos []int) string {
+	words := make([]string, 0, len(pos))
+	for _, p := range pos {
+		words = append(words, ruOrdinal(p, 0))
+	}
+	return ru.join(words) + " из подходящих дней"
+}
+
+func (russian) until(date time.Time) string {
+	return fmt.Sprintf("до %d %s %d", date.Day(), ruMonthsGenitive[date.Month()-1], date.Year())
+}
+
+func (russian) count(n int) string {
+	return fmt.Sprintf("%d %s", n, ruPlural(n, [3]string{"раз", "раза", "раз"}))
+}
+
+func (russian) shift(s Shift) string {
+	if s == ShiftPrev {
+		return "с переносом на предыдущий рабочий день"
+	}
+	return "с переносом на следующий рабочий день"
+}
+
+func (russian) fromDone() string {
+	return "считая от выполнения"
+}
+
+func (russian) join(items []string) string {
+	return joinWords(items, "и")
+}
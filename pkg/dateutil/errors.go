@@ -0,0 +1,34 @@
+package dateutil
+
+import "errors"
+
+// Ошибки пакета. Подробные ошибки разбора оборачивают их, поэтому вид
+// ошибки проверяется через errors.Is без сравнения текста.
+var (
+	// ErrInvalidRule — некорректная запись правила повторения
+	ErrInvalidRule = errors.New("некорректное правило повторения")
+	// ErrInvalidDate — некорректная дата
+	ErrInvalidDate = errors.New("некорректная дата")
+	// ErrInvalidCalendar — некорректный файл производственного календаря
+	ErrInvalidCalendar = errors.New("некорректный календарь")
+	// ErrNoDate — правило не даёт ни одной подходящей даты
+	ErrNoDate = errors.New("правило не даёт ни одной подходящей даты")
+	// ErrSeriesEnded возвращается, когда серия повторений закончилась
+	// по условию until или count
+	ErrSeriesEnded = errors.New("серия повторений завершена")
+)
+
+// kindError — ошибка с подробным текстом, которая через errors.Is
+// совпадает с одной из ошибок пакета
+type kindError struct {
+	kind error
+	msg  string
+}
+
+func (e *kindError) Error() string {
+	return e.msg
+}
+
+func (e *kindError) Unwrap() error {
+	return e.kind
+}
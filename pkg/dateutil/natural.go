@@ -0,0 +1,433 @@
+package dateutil
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	errNaturalDate = &kindError{ErrInvalidDate, "не удалось разобрать дату"}
+	errNaturalRule = &kindError{ErrInvalidRule, "не удалось разобрать правило повторения"}
+)
+
+// ParseDateInput разбирает дату в формате ГГГГММДД или словами: "завтра",
+// "через 3 дня", "next friday", "15 марта". Относительные даты
+// отсчитываются от календарной даты now.
+func ParseDateInput(value string, now time.Time) (time.Time, error) {
+	date, err := time.Parse(DateFormat, value)
+	if err == nil {
+		return date, nil
+	}
+	if natural, natErr := ParseNaturalDate(value, now); natErr == nil {
+		return natural, nil
+	}
+	return time.Time{}, fmt.Errorf("%w %q", ErrInvalidDate, value)
+}
+
+// ParseRuleInput разбирает правило повторения в обычной записи или словами:
+// "каждый понедельник и пятницу", "every 2 weeks". Дата начала start нужна
+// для правил вида "каждый месяц", в которых день месяца не указан.
+// Если не подходит ни один вариант, возвращается ошибка обычного разбора.
+func ParseRuleInput(value string, start time.Time) (Rule, error) {
+	rule, err := ParseRule(value)
+	if err == nil {
+		return rule, nil
+	}
+	if natural, natErr := ParseNaturalRule(value, start); natErr == nil {
+		return natural, nil
+	}
+	return nil, err
+}
+
+// naturalTokens приводит фразу к списку слов в нижнем регистре
+func naturalTokens(text string) []string {
+	text = strings.ToLower(strings.ReplaceAll(text, "ё", "е"))
+	text = strings.NewReplacer(",", " ", ";", " ").Replace(text)
+	return strings.Fields(text)
+}
+
+// naturalNumber разбирает число с необязательным окончанием порядкового
+// числительного: "3", "15-го", "21st". ordinal сообщает, было ли окончание.
+func naturalNumber(token string) (n int, ordinal bool, ok bool) {
+	i := 0
+	for i < len(token) && token[i] >= '0' && token[i] <= '9' {
+		i++
+	}
+	if i == 0 || i > 4 {
+		return 0, false, false
+	}
+	n, _ = strconv.Atoi(token[:i])
+
+	switch token[i:] {
+	case "":
+		return n, false, true
+	case "-го", "-е", "-ое", "-й", "-ого", "th", "st", "nd", "rd":
+		return n, true, true
+	}
+	return 0, false, false
+}
+
+// Слова, после которых "день" означает рабочий день: "рабочий день", "business day"
+var naturalBusinessWords = []string{"рабочий", "business"}
+
+var naturalWeekdays = map[string]int{
+	"monday": 1, "mondays": 1, "mon": 1,
+	"tuesday": 2, "tuesdays": 2, "tue": 2,
+	"wednesday": 3, "wednesdays": 3, "wed": 3,
+	"thursday": 4, "thursdays": 4, "thu": 4,
+	"friday": 5, "fridays": 5, "fri": 5,
+	"saturday": 6, "saturdays": 6, "sat": 6,
+	"sunday": 7, "sundays": 7, "sun": 7,
+}
+
+// Основы русских названий дней недели: "пятница", "пятницу", "пятницам"
+var naturalWeekdayStems = []string{"понедельн", "вторн", "сред", "четверг", "пятниц", "суббот", "воскресен"}
+
+// naturalWeekday возвращает день недели от 1 (понедельник) до 7 (воскресенье)
+func naturalWeekday(token string) (int, bool) {
+	if day, ok := naturalWeekdays[token]; ok {
+		return day, true
+	}
+	for i, stem := range naturalWeekdayStems {
+		if strings.HasPrefix(token, stem) {
+			return i + 1, true
+		}
+	}
+	return 0, false
+}
+
+var naturalUnits = map[string]unit{
+	"день": unitDay, "дня": unitDay, "дней": unitDay, "дн": unitDay,
+	"day": unitDay, "days": unitDay,
+	"неделя": unitWeek, "неделю": unitWeek, "недели": unitWeek, "недель": unitWeek,
+	"week": unitWeek, "weeks": unitWeek,
+	"месяц": unitMonth, "месяца": unitMonth, "месяцев": unitMonth,
+	"month": unitMonth, "months": unitMonth,
+	"год": unitYear, "года": unitYear, "лет": unitYear,
+	"year": unitYear, "years": unitYear,
+	"час": unitHour, "часа": unitHour, "часов": unitHour,
+	"hour": unitHour, "hours": unitHour,
+	"минуту": unitMinute, "минуты": unitMinute, "минут": unitMinute,
+	"minute": unitMinute, "minutes": unitMinute, "min": unitMinute, "mins": unitMinute,
+}
+
+// Основы названий месяцев в любом падеже и английские названия
+var naturalMonthStems = []string{"январ", "феврал", "март", "апрел", "ма", "июн", "июл", "август",
+	"сентябр", "октябр", "ноябр", "декабр"}
+
+func naturalMonth(token string) (time.Month, bool) {
+	for m := time.January; m <= time.December; m++ {
+		name := strings.ToLower(m.String())
+		if token == name || token == name[:3] {
+			return m, true
+		}
+	}
+	for i, stem := range naturalMonthStems {
+		// "ма" — только "мая" и "май", иначе совпадёт слишком многое
+		if stem == "ма" && token != "мая" && token != "май" {
+			continue
+		}
+		if strings.HasPrefix(token, stem) {
+			return time.Month(i + 1), true
+		}
+	}
+	return 0, false
+}
+
+// ParseNaturalDate разбирает дату, записанную словами: "сегодня", "завтра",
+// "послезавтра", "через 3 дня", "in 2 weeks", "в пятницу", "next friday",
+// "15 марта", "march 15 2027"
+func ParseNaturalDate(text string, now time.Time) (time.Time, error) {
+	today := dayOf(now, time.UTC)
+	tokens := naturalTokens(text)
+
+	switch strings.Join(tokens, " ") {
+	case "сегодня", "today":
+		return today, nil
+	case "завтра", "tomorrow":
+		return today.AddDate(0, 0, 1), nil
+	case "послезавтра", "day after tomorrow", "the day after tomorrow":
+		return today.AddDate(0, 0, 2), nil
+	case "вчера", "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	}
+	if len(tokens) == 0 {
+		return time.Time{}, errNaturalDate
+	}
+
+	// "через 3 дня", "через неделю", "in 2 weeks", "in a month"
+	if tokens[0] == "через" || tokens[0] == "in" {
+		n, rest := 1, tokens[1:]
+		if len(rest) == 2 && rest[0] != "a" && rest[0] != "an" {
+			num, ordinal, ok := naturalNumber(rest[0])
+			if !ok || ordinal || num > 1000 {
+				return time.Time{}, errNaturalDate
+			}
+			n = num
+		}
+		if len(rest) == 2 {
+			rest = rest[1:]
+		}
+		if len(rest) != 1 {
+			return time.Time{}, errNaturalDate
+		}
+		u, ok := naturalUnits[rest[0]]
+		if !ok {
+			return time.Time{}, errNaturalDate
+		}
+		switch u {
+		case unitDay:
+			return today.AddDate(0, 0, n), nil
+		case unitWeek:
+			return today.AddDate(0, 0, 7*n), nil
+		case unitMonth:
+			return today.AddDate(0, n, 0), nil
+		case unitYear:
+			return today.AddDate(n, 0, 0), nil
+		}
+		return time.Time{}, errNaturalDate
+	}
+
+	var (
+		weekday, day, year int
+		month              time.Month
+	)
+	for _, token := range tokens {
+		switch token {
+		case "в", "во", "on", "next", "this", "the", "of", "следующий", "следующую", "следующее", "эту", "этот":
+			continue
+		}
+		if wd, ok := naturalWeekday(token); ok && weekday == 0 {
+			weekday = wd
+			continue
+		}
+		if m, ok := naturalMonth(token); ok && month == 0 {
+			month = m
+			continue
+		}
+		if n, _, ok := naturalNumber(token); ok {
+			switch {
+			case day == 0 && n >= 1 && n <= 31:
+				day = n
+				continue
+			case year == 0 && day != 0 && n >= 1000:
+				year = n
+				continue
+			}
+		}
+		return time.Time{}, errNaturalDate
+	}
+
+	switch {
+	case weekday != 0 && month == 0 && day == 0:
+		// Ближайший такой день недели после сегодняшнего
+		shift := (weekday%7 - int(today.Weekday()) + 7) % 7
+		if shift == 0 {
+			shift = 7
+		}
+		return today.AddDate(0, 0, shift), nil
+	case month != 0 && day != 0:
+		explicitYear := year != 0
+		if !explicitYear {
+			year = today.Year()
+		}
+		if day > lastDayOfMonth(year, month) && !(month == time.February && day == 29 && !explicitYear) {
+			return time.Time{}, &kindError{ErrInvalidDate, fmt.Sprintf("в месяце нет %d-го числа", day)}
+		}
+		date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+		// Без года берётся ближайшая такая дата, не раньше сегодняшней
+		for !explicitYear && (date.Before(today) || date.Day() != day) {
+			year++
+			date = time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+		}
+		return date, nil
+	}
+	return time.Time{}, errNaturalDate
+}
+
+// ParseNaturalRule разбирает правило повторения, записанное словами, и
+// возвращает равносильное правило в обычной записи: "каждый понедельник и
+// пятницу" — "w 1,5", "every 2 weeks" — "d 14", "15-го числа каждого
+// месяца" — "m 15". Дата начала задаёт день месяца для "каждый месяц".
+func ParseNaturalRule(text string, start time.Time) (Rule, error) {
+	tokens := naturalTokens(text)
+
+	var (
+		every, business bool
+		n               int
+		u               = unit(-1)
+		weekdays        []int
+		monthDays       []int
+	)
+	setUnit := func(next unit) error {
+		if u >= 0 && u != next {
+			return errNaturalRule
+		}
+		u = next
+		return nil
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		next := ""
+		if i+1 < len(tokens) {
+			next = tokens[i+1]
+		}
+
+		switch token {
+		case "каждый", "каждую", "каждое", "каждые", "каждого", "каждой", "every", "each", "по", "раз":
+			every = true
+			continue
+		case "и", "в", "во", "на", "числа", "число", "on", "the", "of", "and", "a":
+			continue
+		case "ежедневно", "daily":
+			every = true
+			if err := setUnit(unitDay); err != nil {
+				return nil, err
+			}
+			continue
+		case "еженедельно", "weekly":
+			every = true
+			if err := setUnit(unitWeek); err != nil {
+				return nil, err
+			}
+			continue
+		case "ежемесячно", "monthly":
+			every = true
+			if err := setUnit(unitMonth); err != nil {
+				return nil, err
+			}
+			continue
+		case "ежегодно", "yearly", "annually":
+			every = true
+			if err := setUnit(unitYear); err != nil {
+				return nil, err
+			}
+			continue
+		case "ежечасно", "hourly":
+			every = true
+			if err := setUnit(unitHour); err != nil {
+				return nil, err
+			}
+			continue
+		case "будням", "будни", "weekdays", "weekday":
+			weekdays = append(weekdays, 1, 2, 3, 4, 5)
+			continue
+		case "выходным", "выходные", "weekends", "weekend":
+			weekdays = append(weekdays, 6, 7)
+			continue
+		case "рабочий", "business":
+			business = true
+			continue
+		case "через", "other":
+			// "через день", "every other week" — раз в два периода
+			every = true
+			if n == 0 {
+				n = 2
+			}
+			continue
+		case "последний", "последнее", "last":
+			if u, ok := naturalUnits[next]; ok && u == unitDay {
+				monthDays = append(monthDays, -1)
+				i++
+				continue
+			}
+			return nil, errNaturalRule
+		}
+
+		if day, ok := naturalWeekday(token); ok {
+			weekdays = append(weekdays, day)
+			continue
+		}
+		if tokenUnit, ok := naturalUnits[token]; ok {
+			// "день" в "рабочий день" — часть названия, а не единица шага
+			if business && tokenUnit == unitDay {
+				continue
+			}
+			if err := setUnit(tokenUnit); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if num, ordinal, ok := naturalNumber(token); ok {
+			// "каждый 5-й рабочий день", "every 5th business day"
+			if slices.Contains(naturalBusinessWords, next) {
+				n = num
+				continue
+			}
+			if _, isUnit := naturalUnits[next]; isUnit && !ordinal {
+				n = num
+				continue
+			}
+			if ordinal || next == "числа" || next == "число" {
+				monthDays = append(monthDays, num)
+				continue
+			}
+		}
+		return nil, errNaturalRule
+	}
+
+	// Без "каждый" повторение задают только дни недели или месяца
+	if !every && len(weekdays) == 0 && len(monthDays) == 0 {
+		return nil, errNaturalRule
+	}
+	n = max(n, 1)
+
+	var rule string
+	switch {
+	case business:
+		if u >= 0 || len(weekdays) > 0 || len(monthDays) > 0 {
+			return nil, errNaturalRule
+		}
+		rule = fmt.Sprintf("b %d", n)
+	case len(weekdays) > 0:
+		if len(monthDays) > 0 || u >= 0 && u != unitWeek && u != unitDay {
+			return nil, errNaturalRule
+		}
+		slices.Sort(weekdays)
+		rule = "w " + joinInts(slices.Compact(weekdays))
+		// "каждую вторую неделю по средам", "через понедельник"
+		if n > 1 {
+			rule += fmt.Sprintf(" /%d", n)
+		}
+	case len(monthDays) > 0:
+		if u >= 0 && u != unitMonth {
+			return nil, errNaturalRule
+		}
+		rule = "m " + joinInts(monthDays)
+		if n > 1 {
+			rule += fmt.Sprintf(" /%d", n)
+		}
+	case u == unitDay:
+		rule = fmt.Sprintf("d %d", n)
+	case u == unitWeek:
+		rule = fmt.Sprintf("d %d", 7*n)
+	case u == unitMonth:
+		rule = fmt.Sprintf("m %d", start.Day())
+		// Задача, начатая в последний день длинного месяца, повторяется
+		// в последний день каждого месяца
+		if start.Day() > 28 && start.Day() == lastDayOfMonth(start.Year(), start.Month()) {
+			rule = "m -1"
+		}
+		if n > 1 {
+			rule += fmt.Sprintf(" /%d", n)
+		}
+	case u == unitYear:
+		if n > 1 {
+			rule = fmt.Sprintf("FREQ=YEARLY;INTERVAL=%d", n)
+		} else {
+			rule = "y"
+		}
+	case u == unitHour:
+		rule = fmt.Sprintf("h %d", n)
+	case u == unitMinute:
+		rule = fmt.Sprintf("min %d", n)
+	default:
+		return nil, errNaturalRule
+	}
+
+	return ParseRule(rule)
+}
@@ -1,186 +1,257 @@
 package dateutil
 
 import (
-	"errors"
 	"fmt"
-	"strconv"
-	"strings"
 	"time"
 )
 
 const DateFormat = "20060102"
 
-func NextDate(now time.Time, date string, rule string) (string, error) {
+// Максимальное число месяцев, которое просматривается при поиске даты
+// по правилу "m". Ближайшее 29 февраля может быть через 8 лет, поэтому
+// запас с избытком.
+const maxMonths = 12 * 400
+
+// NextDate возвращает ближайшую после now дату задачи с датой начала date
+// и правилом rule. Текущие дата и время берутся в часовом поясе now.
+// Рабочие дни определяются по календарю cal, даты из except пропускаются.
+// Если у даты начала указано время ("ГГГГММДД ЧЧ:ММ") или правило повторяет
+// задачу чаще раза в сутки, результат тоже содержит время.
+func NextDate(now time.Time, date string, rule string, cal *Calendar, except ...string) (string, error) {
 	if rule == "" {
-		return "", errors.New("повторение не указано")
+		return "", ruleError(rule, "", "повторение не указано")
 	}
 
-	startDate, err := time.Parse(DateFormat, date)
+	startDate, withTime, err := ParseDateTime(date)
 	if err != nil {
-		return "", fmt.Errorf("некорректная дата начала: %v", err)
+		return "", fmt.Errorf("%w начала %q", ErrInvalidDate, date)
 	}
 
-	parts := strings.Fields(rule)
-	if len(parts) == 0 {
-		return "", errors.New("неверный формат правила")
+	r, err := ParseRule(rule)
+	if err != nil {
+		return "", err
 	}
+	r = WithCalendar(r, cal)
 
-	switch parts[0] {
-	case "d":
-		return handleDailyRule(now, startDate, parts)
-	case "y":
-		return handleYearlyRule(now, startDate)
-	case "w":
-		return handleWeeklyRule(now, startDate, parts)
-	case "m":
-		return handleMonthlyRule(now, startDate, parts)
-	default:
-		return "", errors.New("неподдерживаемый формат правила")
+	if len(except) > 0 {
+		dates, err := ParseDates(except)
+		if err != nil {
+			return "", err
+		}
+		r = WithExcept(r, dates)
 	}
-}
 
-func handleDailyRule(now, startDate time.Time, parts []string) (string, error) {
-	if len(parts) != 2 {
-		return "", errors.New("неверный формат правила 'd'")
+	next, err := NextAt(r, now, startDate)
+	if err != nil {
+		return "", err
 	}
-
-	days, err := strconv.Atoi(parts[1])
-	if err != nil || days < 1 || days > 400 {
-		return "", errors.New("некорректное количество дней")
+	if withTime || IsSubDaily(r) {
+		return next.Format(DateTimeFormat), nil
 	}
+	return next.Format(DateFormat), nil
+}
 
-	date := startDate
-	for {
-		date = date.AddDate(0, 0, days)
-		if afterNow(date, now) {
-			break
+// ParseDates разбирает список дат в формате ГГГГММДД
+func ParseDates(values []string) ([]time.Time, error) {
+	dates := make([]time.Time, 0, len(values))
+	for _, v := range values {
+		date, err := time.Parse(DateFormat, v)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q", ErrInvalidDate, v)
 		}
+		dates = append(dates, date)
 	}
-	return date.Format(DateFormat), nil
+	return dates, nil
 }
 
-// Обработка правила "y"
-func handleYearlyRule(now, startDate time.Time) (string, error) {
-	date := startDate
-	for {
-		nextYear := date.Year() + 1
-		nextDate := time.Date(nextYear, date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
-
-		// Исправление для 29 февраля
-		if nextDate.Day() != date.Day() {
-			nextDate = time.Date(nextYear, date.Month()+1, 1, 0, 0, 0, 0, date.Location())
-		}
-
-		if afterNow(nextDate, now) {
-			return nextDate.Format(DateFormat), nil
-		}
-		date = nextDate
+// Правило "d": ближайшая дата вида start + k*N, k >= 1, позже now
+func (r DailyRule) Next(now, startDate time.Time) (time.Time, error) {
+	steps := 1
+	if afterNow(now, startDate) {
+		steps = daysBetween(startDate, dayOf(now, startDate.Location()))/r.Days + 1
 	}
+	return startDate.AddDate(0, 0, steps*r.Days), nil
 }
 
-func handleWeeklyRule(now, startDate time.Time, parts []string) (string, error) {
-	if len(parts) < 2 {
-		return "", errors.New("неверный формат правила 'w'")
+// Правило "y": тот же день в следующем подходящем году. Задача, начатая
+// 29 февраля, в дальнейшем переносится на 1 марта.
+func (YearlyRule) Next(now, startDate time.Time) (time.Time, error) {
+	month, day := startDate.Month(), startDate.Day()
+	if month == time.February && day == 29 {
+		month, day = time.March, 1
 	}
 
-	days := make(map[int]bool)
-	for _, s := range strings.Split(parts[1], ",") {
-		d, err := strconv.Atoi(s)
-		if err != nil || d < 1 || d > 7 {
-			return "", errors.New("недопустимый день недели")
-		}
-		days[d] = true
+	year := startDate.Year() + 1
+	if now.Year() > year {
+		year = now.Year()
 	}
 
-	date := startDate
-	for {
-		currentDay := int(date.Weekday())
-		if currentDay == 0 {
-			currentDay = 7
+	date := time.Date(year, month, day, 0, 0, 0, 0, startDate.Location())
+	if !afterNow(date, now) {
+		date = date.AddDate(1, 0, 0)
+	}
+	return date, nil
+}
+
+// Правило "w": ближайший из указанных дней недели, начиная с даты начала.
+// С интервалом подходят только недели, отстоящие от недели даты начала
+// на кратное интервалу число недель.
+func (r WeeklyRule) Next(now, startDate time.Time) (time.Time, error) {
+	interval := max(r.Interval, 1)
+	anchor := weekStart(startDate)
+	date := firstCandidate(now, startDate)
+
+	// Если в текущей подходящей неделе дня не нашлось,
+	// он точно найдётся в следующей подходящей
+	for i := 0; i < 2; i++ {
+		week := weekStart(date)
+		if rem := daysBetween(anchor, week) / 7 % interval; rem != 0 {
+			week = week.AddDate(0, 0, 7*(interval-rem))
+			date = week
 		}
-		if days[currentDay] && afterNow(date, now) {
-			return date.Format(DateFormat), nil
+
+		for ; date.Before(week.AddDate(0, 0, 7)); date = date.AddDate(0, 0, 1) {
+			currentDay := int(date.Weekday())
+			if currentDay == 0 {
+				currentDay = 7
+			}
+			if contains(r.Days, currentDay) {
+				return date, nil
+			}
 		}
-		date = date.AddDate(0, 0, 1)
 	}
+	return time.Time{}, ErrNoDate
 }
 
-// Обработка правила "m"
-func handleMonthlyRule(now, startDate time.Time, parts []string) (string, error) {
-	if len(parts) < 2 {
-		return "", errors.New("неверный формат правила 'm'")
-	}
+// Правило "m": ближайший из указанных дней в подходящем месяце, начиная с даты начала
+func (r MonthlyRule) Next(now, startDate time.Time) (time.Time, error) {
+	return searchMonths(now, startDate, r.Months, r.Interval, r.firstDayInMonth)
+}
 
-	daysStr := strings.Split(parts[1], ",")
-	var days []int
-	for _, s := range daysStr {
-		d, err := strconv.Atoi(s)
-		if err != nil || (d < -2 || d == 0 || d > 31) {
-			return "", errors.New("недопустимый день месяца")
-		}
-		days = append(days, d)
+// Правило "mw": ближайший из указанных дней недели месяца, начиная с даты начала
+func (r MonthWeekdayRule) Next(now, startDate time.Time) (time.Time, error) {
+	return searchMonths(now, startDate, r.Months, r.Interval, r.firstDayInMonth)
+}
+
+// searchMonths перебирает месяцы из списка months (пустой список — все месяцы),
+// начиная с первой допустимой даты, и возвращает первую дату, найденную pick.
+// С интервалом подходят только месяцы, отстоящие от месяца даты начала
+// на кратное интервалу число месяцев.
+func searchMonths(now, startDate time.Time, months []int, interval int,
+	pick func(year int, month time.Month, from time.Time) (time.Time, bool)) (time.Time, error) {
+	interval = max(interval, 1)
+	from := firstCandidate(now, startDate)
+
+	// Номер месяца от начала эпохи, чтобы не возиться с переходом через год
+	index := from.Year()*12 + int(from.Month()) - 1
+	anchor := startDate.Year()*12 + int(startDate.Month()) - 1
+	if rem := (index - anchor) % interval; rem != 0 {
+		index += interval - rem
 	}
 
-	var months []int
-	if len(parts) > 2 {
-		for _, s := range strings.Split(parts[2], ",") {
-			m, err := strconv.Atoi(s)
-			if err != nil || m < 1 || m > 12 {
-				return "", errors.New("недопустимый месяц")
+	// Последовательность подходящих месяцев повторяется не позже чем через
+	// maxMonths шагов, поэтому дальше искать бессмысленно
+	for i := 0; i < maxMonths; i++ {
+		year, month := index/12, time.Month(index%12+1)
+		if len(months) == 0 || contains(months, int(month)) {
+			if date, ok := pick(year, month, from); ok {
+				return date, nil
 			}
-			months = append(months, m)
 		}
+		index += interval
 	}
+	return time.Time{}, ErrNoDate
+}
 
-	date := startDate
-	for {
-		year, month, _ := date.Date()
-		currentMonth := int(month)
+// weekStart возвращает понедельник недели, в которую попадает date
+func weekStart(date time.Time) time.Time {
+	shift := (int(date.Weekday()) + 6) % 7
+	year, month, day := date.Date()
+	return time.Date(year, month, day-shift, 0, 0, 0, 0, date.Location())
+}
+
+// firstDayInMonth возвращает наименьший из дней правила в указанном месяце, не раньше from
+func (r MonthlyRule) firstDayInMonth(year int, month time.Month, from time.Time) (time.Time, bool) {
+	lastDay := lastDayOfMonth(year, month)
 
-		// Проверяем, подходит ли месяц
-		if len(months) > 0 && !contains(months, currentMonth) {
-			date = time.Date(year, month+1, 1, 0, 0, 0, 0, date.Location())
+	var result time.Time
+	found := false
+	for _, d := range r.Days {
+		if d < 0 {
+			d = lastDay + d + 1
+		}
+		if d > lastDay {
 			continue
 		}
+		date := time.Date(year, month, d, 0, 0, 0, 0, from.Location())
+		if date.Before(from) {
+			continue
+		}
+		if !found || date.Before(result) {
+			result, found = date, true
+		}
+	}
+	return result, found
+}
 
-		// Ищем ближайший подходящий день в текущем месяце
-		var candidates []time.Time
-		for _, d := range days {
-			var targetDay time.Time
-			if d < 0 {
-				lastDay := lastDayOfMonth(year, month)
-				adjustedDay := lastDay + d + 1
-				if adjustedDay < 1 {
-					adjustedDay = 1
-				}
-				targetDay = time.Date(year, month, adjustedDay, 0, 0, 0, 0, date.Location())
-			} else {
-				maxDay := lastDayOfMonth(year, month)
-				if d > maxDay {
-					continue
-				}
-				targetDay = time.Date(year, month, d, 0, 0, 0, 0, date.Location())
-			}
+// firstDayInMonth возвращает наименьший из дней правила в указанном месяце, не раньше from
+func (r MonthWeekdayRule) firstDayInMonth(year int, month time.Month, from time.Time) (time.Time, bool) {
+	lastDay := lastDayOfMonth(year, month)
+	firstWeekday := int(time.Date(year, month, 1, 0, 0, 0, 0, time.UTC).Weekday())
 
-			if (targetDay.After(startDate) || targetDay.Equal(startDate)) && afterNow(targetDay, now) {
-				candidates = append(candidates, targetDay)
-			}
+	var result time.Time
+	found := false
+	for _, wd := range r.Days {
+		// День месяца первого вхождения дня недели (1 — понедельник, 7 — воскресенье)
+		first := 1 + (wd.Day%7-firstWeekday+7)%7
+
+		var d int
+		if wd.N > 0 {
+			d = first + 7*(wd.N-1)
+		} else {
+			last := first + 7*((lastDay-first)/7)
+			d = last + 7*(wd.N+1)
+		}
+		if d < 1 || d > lastDay {
+			continue
+		}
+
+		date := time.Date(year, month, d, 0, 0, 0, 0, from.Location())
+		if date.Before(from) {
+			continue
+		}
+		if !found || date.Before(result) {
+			result, found = date, true
 		}
+	}
+	return result, found
+}
 
-		// Выбираем минимальную подходящую дату
-		if len(candidates) > 0 {
-			minDate := candidates[0]
-			for _, c := range candidates {
-				if c.Before(minDate) {
-					minDate = c
-				}
+// possible проверяет, что хотя бы один из дней правила существует
+// хотя бы в одном из его месяцев
+func (r MonthlyRule) possible() bool {
+	months := r.Months
+	if len(months) == 0 {
+		return true
+	}
+	for _, m := range months {
+		maxDay := lastDayOfMonth(2000, time.Month(m)) // 2000 — високосный год
+		for _, d := range r.Days {
+			if d < 0 || d <= maxDay {
+				return true
 			}
-			return minDate.Format(DateFormat), nil
 		}
+	}
+	return false
+}
 
-		// Переход к следующему месяцу
-		date = time.Date(year, month+1, 1, 0, 0, 0, 0, date.Location())
+// firstCandidate возвращает первую дату, которая не раньше даты начала и позже now
+func firstCandidate(now, startDate time.Time) time.Time {
+	if afterNow(startDate, now) {
+		return startDate
 	}
+	year, month, day := now.Date()
+	return time.Date(year, month, day+1, 0, 0, 0, 0, startDate.Location())
 }
 
 func lastDayOfMonth(year int, month time.Month) int {
@@ -196,8 +267,32 @@ func contains(slice []int, item int) bool {
 	return false
 }
 
+// afterNow проверяет, что календарная дата date позже календарной даты now.
+// Каждая дата берётся в своём часовом поясе: полночь в Москве — это
+// уже следующий день, хотя в UTC ещё предыдущий.
 func afterNow(date, now time.Time) bool {
-	date = date.Truncate(24 * time.Hour)
-	now = now.Truncate(24 * time.Hour)
-	return date.After(now)
+	return dayOf(date, time.UTC).After(dayOf(now, time.UTC))
+}
+
+// dayOf возвращает полночь календарной даты t в часовом поясе loc
+func dayOf(t time.Time, loc *time.Location) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, loc)
+}
+
+// secondsBetween возвращает число секунд от from до to. Интервалы между
+// датами считаются в секундах Unix, а не через time.Duration: она
+// переполняется уже на интервалах около 290 лет.
+func secondsBetween(from, to time.Time) int64 {
+	return to.Unix() - from.Unix()
+}
+
+// addSeconds — обратная к secondsBetween операция: t плюс n секунд
+func addSeconds(t time.Time, n int64) time.Time {
+	return time.Unix(t.Unix()+n, 0).In(t.Location())
+}
+
+// daysBetween возвращает количество дней между датами
+func daysBetween(from, to time.Time) int {
+	return int(secondsBetween(from, to) / (24 * 60 * 60))
 }
@@ -0,0 +1,64 @@
+package dateutil
+
+import (
+	"iter"
+	"time"
+)
+
+// Occurrences возвращает даты задачи с датой начала start и правилом rule,
+// попадающие в интервал [from, to]. Сама дата начала тоже считается вхождением.
+// Нулевое to означает интервал без верхней границы, limit <= 0 — без ограничения
+// количества. Перебор прекращается, когда правило больше не даёт дат.
+// Количество повторений из условия окончания правила считается так же, как
+// в Bounded.Next: от start, без пропущенных дат.
+func Occurrences(rule Rule, start, from, to time.Time, limit int) iter.Seq[time.Time] {
+	return func(yield func(time.Time) bool) {
+		// Повторения считаются здесь, по мере перебора: Next с count
+		// пересчитывал бы серию от start для каждой даты
+		count := RuleEnd(rule).Count
+		rule := withoutCount(rule)
+		inRange := func(date time.Time) bool {
+			return to.IsZero() || !afterNow(date, to)
+		}
+
+		n, seen := 0, 1
+		if isExcluded(rule, start) {
+			seen = 0
+		}
+		// Правила по дням сами отбрасывают время, поэтому минута до from
+		// для них означает предыдущий день
+		now := from.Add(-time.Minute)
+		if !afterNow(from, start) && !isExcluded(rule, start) {
+			if !inRange(start) || !yield(start) {
+				return
+			}
+			n++
+			now = start
+		} else if count > 0 {
+			// Вхождения до from тоже расходуют count, поэтому их приходится перебрать
+			now = start
+		}
+
+		for limit <= 0 || n < limit {
+			date, err := NextAt(rule, now, start)
+			if err != nil || !inRange(date) {
+				return
+			}
+			now = date
+
+			if count > 0 {
+				if seen++; seen > count {
+					return
+				}
+				if afterNow(from, date) {
+					continue
+				}
+			}
+
+			if !yield(date) {
+				return
+			}
+			n++
+		}
+	}
+}
@@ -0,0 +1,476 @@
+package dateutil
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var weekdayCodes = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// WeekdayNum — элемент BYDAY: день недели с необязательным порядковым номером
+// (2MO — второй понедельник, -1FR — последняя пятница)
+type WeekdayNum struct {
+	N   int // 0 — каждый такой день недели
+	Day time.Weekday
+}
+
+// RRule — правило повторения в формате RFC 5545 (RRULE)
+type RRule struct {
+	Freq       string // DAILY, WEEKLY, MONTHLY или YEARLY
+	Interval   int
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []int
+	BySetPos   []int
+	Count      int       // 0 — без ограничения; дата начала считается первым повторением
+	Until      time.Time // нулевое значение — без ограничения
+	Wkst       time.Weekday
+}
+
+// isRRule проверяет, записано ли правило в формате RRULE
+func isRRule(rule string) bool {
+	upper := strings.ToUpper(strings.TrimSpace(rule))
+	return strings.HasPrefix(upper, "RRULE:") || strings.HasPrefix(upper, "FREQ=")
+}
+
+func parseRRule(rule string) (*RRule, error) {
+	body := strings.TrimSpace(rule)
+	if strings.HasPrefix(strings.ToUpper(body), "RRULE:") {
+		body = body[len("RRULE:"):]
+	}
+
+	r := &RRule{Interval: 1, Wkst: time.Monday}
+	seen := make(map[string]bool)
+
+	for _, part := range strings.Split(body, ";") {
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return nil, ruleError(rule, "RRULE", fmt.Sprintf("неверный параметр RRULE: %q", part))
+		}
+		key = strings.ToUpper(key)
+		value = strings.ToUpper(value)
+		if seen[key] {
+			return nil, ruleError(rule, key, fmt.Sprintf("параметр %s указан повторно", key))
+		}
+		seen[key] = true
+
+		var err error
+		switch key {
+		case "FREQ":
+			switch value {
+			case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+				r.Freq = value
+			default:
+				return nil, ruleError(rule, key, fmt.Sprintf("неподдерживаемая частота FREQ=%s", value))
+			}
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(value)
+			if err != nil || r.Interval < 1 || r.Interval > maxInterval {
+				return nil, ruleError(rule, key, fmt.Sprintf("некорректный INTERVAL: ожидается число от 1 до %d", maxInterval))
+			}
+		case "COUNT":
+			r.Count, err = strconv.Atoi(value)
+			if err != nil || r.Count < 1 || r.Count > maxCount {
+				return nil, ruleError(rule, key, fmt.Sprintf("некорректный COUNT: ожидается число от 1 до %d", maxCount))
+			}
+		case "UNTIL":
+			r.Until, err = parseUntil(value)
+			if err != nil {
+				return nil, ruleError(rule, key, err.Error())
+			}
+		case "WKST":
+			day, ok := weekdayCodes[value]
+			if !ok {
+				return nil, ruleError(rule, key, "некорректный WKST")
+			}
+			r.Wkst = day
+		case "BYDAY":
+			r.ByDay, err = parseByDay(value)
+			if err != nil {
+				return nil, ruleError(rule, key, err.Error())
+			}
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = parseIntList(value, -31, 31)
+			if err != nil {
+				return nil, ruleError(rule, key, "некорректный BYMONTHDAY")
+			}
+		case "BYMONTH":
+			r.ByMonth, err = parseIntList(value, 1, 12)
+			if err != nil {
+				return nil, ruleError(rule, key, "некорректный BYMONTH")
+			}
+		case "BYSETPOS":
+			r.BySetPos, err = parseIntList(value, -366, 366)
+			if err != nil {
+				return nil, ruleError(rule, key, "некорректный BYSETPOS")
+			}
+		default:
+			return nil, ruleError(rule, key, fmt.Sprintf("неподдерживаемый параметр RRULE: %s", key))
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, ruleError(rule, "FREQ", "в правиле RRULE не указан FREQ")
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return nil, ruleError(rule, "COUNT", "COUNT и UNTIL не могут использоваться вместе")
+	}
+	if len(r.BySetPos) > 0 && len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 && len(r.ByMonth) == 0 {
+		return nil, ruleError(rule, "BYSETPOS", "BYSETPOS используется только вместе с другими BY-параметрами")
+	}
+	for _, wd := range r.ByDay {
+		if wd.N == 0 {
+			continue
+		}
+		if r.Freq != "MONTHLY" && r.Freq != "YEARLY" {
+			return nil, ruleError(rule, "BYDAY", "порядковый номер в BYDAY допустим только для MONTHLY и YEARLY")
+		}
+		if (r.Freq == "MONTHLY" || len(r.ByMonth) > 0) && (wd.N < -5 || wd.N > 5) {
+			return nil, ruleError(rule, "BYDAY", "некорректный порядковый номер в BYDAY")
+		}
+	}
+
+	return r, nil
+}
+
+// String возвращает правило в виде RRULE без префикса "RRULE:"
+func (r *RRule) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, wd := range r.ByDay {
+			days[i] = wd.String()
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+joinInts(r.ByMonthDay))
+	}
+	if len(r.ByMonth) > 0 {
+		parts = append(parts, "BYMONTH="+joinInts(r.ByMonth))
+	}
+	if len(r.BySetPos) > 0 {
+		parts = append(parts, "BYSETPOS="+joinInts(r.BySetPos))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.Format(DateFormat))
+	}
+	if r.Wkst != time.Monday {
+		parts = append(parts, "WKST="+weekdayCode(r.Wkst))
+	}
+	return strings.Join(parts, ";")
+}
+
+func (wd WeekdayNum) String() string {
+	if wd.N == 0 {
+		return weekdayCode(wd.Day)
+	}
+	return strconv.Itoa(wd.N) + weekdayCode(wd.Day)
+}
+
+func weekdayCode(day time.Weekday) string {
+	for code, d := range weekdayCodes {
+		if d == day {
+			return code
+		}
+	}
+	return ""
+}
+
+func parseUntil(value string) (time.Time, error) {
+	if len(value) < 8 {
+		return time.Time{}, errors.New("некорректный UNTIL")
+	}
+	if len(value) > 8 {
+		rest := strings.TrimSuffix(value[8:], "Z")
+		if len(rest) != 7 || rest[0] != 'T' {
+			return time.Time{}, errors.New("некорректный UNTIL")
+		}
+		if _, err := strconv.Atoi(rest[1:]); err != nil {
+			return time.Time{}, errors.New("некорректный UNTIL")
+		}
+	}
+	until, err := time.Parse(DateFormat, value[:8])
+	if err != nil {
+		return time.Time{}, errors.New("некорректный UNTIL")
+	}
+	return until, nil
+}
+
+func parseByDay(value string) ([]WeekdayNum, error) {
+	var days []WeekdayNum
+	for _, s := range strings.Split(value, ",") {
+		if len(s) < 2 {
+			return nil, errors.New("некорректный BYDAY")
+		}
+		day, ok := weekdayCodes[s[len(s)-2:]]
+		if !ok {
+			return nil, errors.New("некорректный BYDAY")
+		}
+		wd := WeekdayNum{Day: day}
+		if prefix := s[:len(s)-2]; prefix != "" {
+			n, err := strconv.Atoi(prefix)
+			if err != nil || n == 0 || n < -53 || n > 53 {
+				return nil, errors.New("некорректный BYDAY")
+			}
+			wd.N = n
+		}
+		days = append(days, wd)
+	}
+	return days, nil
+}
+
+func parseIntList(value string, min, max int) ([]int, error) {
+	var list []int
+	for _, s := range strings.Split(value, ",") {
+		v, err := strconv.Atoi(strings.TrimPrefix(s, "+"))
+		if err != nil || v == 0 || v < min || v > max {
+			return nil, errors.New("некорректное значение")
+		}
+		list = append(list, v)
+	}
+	return list, nil
+}
+
+// Next возвращает первую дату серии, которая позже и now, и startDate.
+// COUNT считается так же, как count в Bounded.
+func (r *RRule) Next(now, startDate time.Time) (time.Time, error) {
+	if r.Count > 0 {
+		return countNext(r.uncounted(), r.Count, now, startDate)
+	}
+
+	period := r.periodStart(startDate)
+	limit := r.cycle()
+	// Номер вхождения не важен, поэтому можно сразу перейти
+	// к периоду, предшествующему now
+	if afterNow(now, startDate) {
+		if skip := r.periodsBetween(period, r.periodStart(now))/r.Interval - 1; skip > 0 {
+			period = r.advance(period, skip)
+		}
+	}
+
+	for i := 0; i < limit; i++ {
+		for _, date := range r.expand(period, startDate) {
+			if date.Before(startDate) {
+				continue
+			}
+			if !r.Until.IsZero() && date.After(r.Until) {
+				return time.Time{}, ErrSeriesEnded
+			}
+			if date.After(startDate) && afterNow(date, now) {
+				return date, nil
+			}
+		}
+		period = r.advance(period, 1)
+	}
+	return time.Time{}, ErrNoDate
+}
+
+// uncounted возвращает копию правила без COUNT
+func (r *RRule) uncounted() *RRule {
+	base := *r
+	base.Count = 0
+	return &base
+}
+
+// cycle возвращает число периодов в 400 годах. Григорианский календарь
+// повторяется с этим циклом, поэтому если за цикл правило не дало ни одной
+// даты, оно не даст её никогда.
+func (r *RRule) cycle() int {
+	switch r.Freq {
+	case "WEEKLY":
+		return 20871
+	case "MONTHLY":
+		return 4800
+	case "YEARLY":
+		return 400
+	default:
+		return 146097
+	}
+}
+
+// periodStart возвращает начало периода (день, неделя, месяц, год), содержащего date
+func (r *RRule) periodStart(date time.Time) time.Time {
+	year, month, day := date.Date()
+	switch r.Freq {
+	case "WEEKLY":
+		shift := (int(date.Weekday()) - int(r.Wkst) + 7) % 7
+		return time.Date(year, month, day-shift, 0, 0, 0, 0, time.UTC)
+	case "MONTHLY":
+		return time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	case "YEARLY":
+		return time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// advance сдвигает начало периода на n интервалов вперёд
+func (r *RRule) advance(period time.Time, n int) time.Time {
+	switch r.Freq {
+	case "WEEKLY":
+		return period.AddDate(0, 0, 7*r.Interval*n)
+	case "MONTHLY":
+		return period.AddDate(0, r.Interval*n, 0)
+	case "YEARLY":
+		return period.AddDate(r.Interval*n, 0, 0)
+	default:
+		return period.AddDate(0, 0, r.Interval*n)
+	}
+}
+
+// periodsBetween возвращает количество целых периодов между началами периодов from и to
+func (r *RRule) periodsBetween(from, to time.Time) int {
+	switch r.Freq {
+	case "WEEKLY":
+		return daysBetween(from, to) / 7
+	case "MONTHLY":
+		return (to.Year()-from.Year())*12 + int(to.Month()) - int(from.Month())
+	case "YEARLY":
+		return to.Year() - from.Year()
+	default:
+		return daysBetween(from, to)
+	}
+}
+
+// expand возвращает отсортированные даты серии внутри периода
+func (r *RRule) expand(period, startDate time.Time) []time.Time {
+	var end time.Time
+	switch r.Freq {
+	case "WEEKLY":
+		end = period.AddDate(0, 0, 7)
+	case "MONTHLY":
+		end = period.AddDate(0, 1, 0)
+	case "YEARLY":
+		end = period.AddDate(1, 0, 0)
+	default:
+		end = period.AddDate(0, 0, 1)
+	}
+
+	var dates []time.Time
+	for d := period; d.Before(end); d = d.AddDate(0, 0, 1) {
+		if r.match(d, startDate) {
+			dates = append(dates, d)
+		}
+	}
+
+	if len(r.BySetPos) == 0 || len(dates) == 0 {
+		return dates
+	}
+
+	var positions []int
+	for _, pos := range r.BySetPos {
+		idx := pos - 1
+		if pos < 0 {
+			idx = len(dates) + pos
+		}
+		if idx >= 0 && idx < len(dates) && !contains(positions, idx) {
+			positions = append(positions, idx)
+		}
+	}
+	sort.Ints(positions)
+
+	selected := make([]time.Time, 0, len(positions))
+	for _, idx := range positions {
+		selected = append(selected, dates[idx])
+	}
+	return selected
+}
+
+// match проверяет, удовлетворяет ли дата BY-параметрам правила
+func (r *RRule) match(date, startDate time.Time) bool {
+	year, month, day := date.Date()
+
+	if len(r.ByMonth) > 0 && !contains(r.ByMonth, int(month)) {
+		return false
+	}
+
+	if len(r.ByMonthDay) > 0 {
+		last := lastDayOfMonth(year, month)
+		found := false
+		for _, d := range r.ByMonthDay {
+			if d == day || (d < 0 && last+d+1 == day) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(r.ByDay) > 0 {
+		found := false
+		for _, wd := range r.ByDay {
+			if wd.Day == date.Weekday() && (wd.N == 0 || r.matchOrdinal(date, wd.N)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	// Значения по умолчанию берутся из даты начала
+	switch r.Freq {
+	case "WEEKLY":
+		if len(r.ByDay) == 0 && date.Weekday() != startDate.Weekday() {
+			return false
+		}
+	case "MONTHLY":
+		if len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 && day != startDate.Day() {
+			return false
+		}
+	case "YEARLY":
+		if len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 {
+			if day != startDate.Day() {
+				return false
+			}
+			if len(r.ByMonth) == 0 && month != startDate.Month() {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// matchOrdinal проверяет порядковый номер дня недели в месяце или году
+func (r *RRule) matchOrdinal(date time.Time, n int) bool {
+	year, month, day := date.Date()
+
+	if r.Freq == "MONTHLY" || len(r.ByMonth) > 0 {
+		if n > 0 {
+			return (day-1)/7+1 == n
+		}
+		return (lastDayOfMonth(year, month)-day)/7+1 == -n
+	}
+
+	yday := date.YearDay()
+	if n > 0 {
+		return (yday-1)/7+1 == n
+	}
+	daysInYear := time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC).YearDay()
+	return (daysInYear-yday)/7+1 == -n
+}
@@ -0,0 +1,629 @@
+package dateutil
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rule — разобранное правило повторения задачи
+type Rule interface {
+	// Next возвращает ближайшую дату по правилу, которая позже now
+	// и даты начала start
+	Next(now, start time.Time) (time.Time, error)
+	// String возвращает каноническую запись правила,
+	// которую ParseRule разбирает в то же самое правило
+	String() string
+}
+
+// RuleError — ошибка разбора правила повторения
+type RuleError struct {
+	Rule  string // исходная запись правила
+	Field string // часть правила, в которой найдена ошибка
+	Msg   string
+}
+
+func (e *RuleError) Error() string {
+	return e.Msg
+}
+
+// Unwrap позволяет проверить ошибку через errors.Is(err, ErrInvalidRule)
+func (e *RuleError) Unwrap() error {
+	return ErrInvalidRule
+}
+
+func ruleError(rule, field, msg string) *RuleError {
+	return &RuleError{Rule: rule, Field: field, Msg: msg}
+}
+
+// DailyRule — правило "d N": каждые N дней
+type DailyRule struct {
+	Days int
+}
+
+// WeeklyRule — правило "w 1,3,5": по указанным дням недели (1 — понедельник, 7 — воскресенье).
+// С интервалом "w 1 /2" — раз в Interval недель, считая от недели даты начала.
+type WeeklyRule struct {
+	Days     []int
+	Interval int // 0 и 1 — каждую неделю
+}
+
+// MonthlyRule — правило "m 5,-1 3,6": по указанным дням месяца (-1 и -2 — последний
+// и предпоследний день) в указанных месяцах; пустой список месяцев означает каждый месяц.
+// С интервалом "m 10 /3" — раз в Interval месяцев, считая от месяца даты начала.
+type MonthlyRule struct {
+	Days     []int
+	Months   []int
+	Interval int // 0 и 1 — каждый месяц
+}
+
+// NthWeekday — N-й день недели месяца: N от 1 до 5 считается с начала месяца,
+// от -1 до -5 — с конца; Day — день недели (1 — понедельник, 7 — воскресенье)
+type NthWeekday struct {
+	N   int
+	Day int
+}
+
+// MonthWeekdayRule — правило "mw 2-2,-1-5 3,6": по N-м дням недели месяца
+// (второй вторник, последняя пятница) в указанных месяцах; пустой список
+// месяцев означает каждый месяц. Интервал задаётся так же, как в правиле "m"
+type MonthWeekdayRule struct {
+	Days     []NthWeekday
+	Months   []int
+	Interval int
+}
+
+// YearlyRule — правило "y": ежегодно в день даты начала
+type YearlyRule struct{}
+
+// End — условие окончания серии повторений
+type End struct {
+	Until time.Time // последняя допустимая дата; нулевое значение — без ограничения
+	Count int       // общее число повторений; 0 — без ограничения
+}
+
+// Bounded — правило с условием окончания: "w 5 until 20261231", "d 3 count 5".
+// Повторения отсчитываются от даты начала, которая сама считается первым,
+// а пропущенные даты (WithExcept) в счёт не входят — так же считается COUNT
+// в правилах iCalendar.
+type Bounded struct {
+	Rule Rule
+	End
+}
+
+// FromDone — правило с отсчётом от выполнения: "d 3 from done". Следующая
+// дата отсчитывается от дня, когда задачу отметили выполненной, поэтому
+// при отметке датой начала служит сам этот день. Next совпадает с вложенным правилом.
+type FromDone struct {
+	Rule Rule
+}
+
+// Except — правило, в серии которого пропускаются отдельные даты.
+// Пропуски не входят в запись правила и хранятся отдельно. Правило
+// с пропусками строится через WithExcept.
+type Except struct {
+	Rule  Rule
+	Dates []time.Time
+}
+
+// ParseRule разбирает запись правила повторения. Ошибки разбора
+// возвращаются в виде *RuleError.
+func ParseRule(rule string) (Rule, error) {
+	if strings.TrimSpace(rule) == "" {
+		return nil, ruleError(rule, "", "повторение не указано")
+	}
+
+	if isRRule(rule) {
+		return parseRRule(rule)
+	}
+
+	parts, opts, err := parseOptions(rule, strings.Fields(rule))
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := parseBaseRule(rule, parts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Shift != ShiftNone {
+		if IsSubDaily(r) {
+			return nil, ruleError(rule, "shift", "перенос 'shift' не применяется к правилам 'h' и 'min'")
+		}
+		r = Shifted{Rule: r, Shift: opts.Shift}
+	}
+	if opts.FromDone {
+		r = FromDone{Rule: r}
+	}
+	if opts.End != (End{}) {
+		r = Bounded{Rule: r, End: opts.End}
+	}
+	return r, nil
+}
+
+// RuleEnd возвращает условие окончания серии, заданное в правиле
+func RuleEnd(rule Rule) End {
+	switch r := rule.(type) {
+	case Bounded:
+		return r.End
+	case *RRule:
+		return End{Until: r.Until, Count: r.Count}
+	case Except:
+		return RuleEnd(r.Rule)
+	case Shifted:
+		return RuleEnd(r.Rule)
+	case FromDone:
+		return RuleEnd(r.Rule)
+	}
+	return End{}
+}
+
+// isExcluded проверяет, пропущена ли дата в серии правила
+func isExcluded(rule Rule, date time.Time) bool {
+	switch r := rule.(type) {
+	case Bounded:
+		return isExcluded(r.Rule, date)
+	case Except:
+		return r.excludes(date) || isExcluded(r.Rule, date)
+	case Shifted:
+		return isExcluded(r.Rule, date)
+	case FromDone:
+		return isExcluded(r.Rule, date)
+	}
+	return false
+}
+
+// RepeatsFromDone проверяет, что следующая дата правила отсчитывается
+// от дня выполнения задачи, а не от её даты
+func RepeatsFromDone(rule Rule) bool {
+	switch r := rule.(type) {
+	case FromDone:
+		return true
+	case Bounded:
+		return RepeatsFromDone(r.Rule)
+	case Except:
+		return RepeatsFromDone(r.Rule)
+	}
+	return false
+}
+
+// ruleOptions — необязательные параметры правила, записываемые после него
+type ruleOptions struct {
+	End
+	Shift    Shift
+	FromDone bool
+}
+
+var optionKeys = []string{"until", "count", "shift", "from"}
+
+// parseOptions отделяет от правила условия окончания "until ГГГГММДД", "count N",
+// перенос с нерабочих дней "shift next|prev" и отсчёт от выполнения "from done"
+func parseOptions(rule string, parts []string) ([]string, ruleOptions, error) {
+	var opts ruleOptions
+
+	i := 1
+	for i < len(parts) && !slices.Contains(optionKeys, parts[i]) {
+		i++
+	}
+
+	for j := i; j < len(parts); j += 2 {
+		key := parts[j]
+		if j+1 >= len(parts) {
+			return nil, opts, ruleError(rule, key, fmt.Sprintf("не указано значение '%s'", key))
+		}
+		value := parts[j+1]
+
+		switch key {
+		case "until":
+			if !opts.Until.IsZero() {
+				return nil, opts, ruleError(rule, key, "условие 'until' указано повторно")
+			}
+			until, err := time.Parse(DateFormat, value)
+			if err != nil {
+				return nil, opts, ruleError(rule, key, "некорректная дата окончания")
+			}
+			opts.Until = until
+		case "count":
+			if opts.Count != 0 {
+				return nil, opts, ruleError(rule, key, "условие 'count' указано повторно")
+			}
+			count, err := strconv.Atoi(value)
+			if err != nil || count < 1 || count > maxCount {
+				return nil, opts, ruleError(rule, key,
+					fmt.Sprintf("некорректное количество повторений: ожидается число от 1 до %d", maxCount))
+			}
+			opts.Count = count
+		case "shift":
+			if opts.Shift != ShiftNone {
+				return nil, opts, ruleError(rule, key, "условие 'shift' указано повторно")
+			}
+			switch value {
+			case "next":
+				opts.Shift = ShiftNext
+			case "prev":
+				opts.Shift = ShiftPrev
+			default:
+				return nil, opts, ruleError(rule, key, "перенос 'shift' может быть только next или prev")
+			}
+		case "from":
+			if opts.FromDone {
+				return nil, opts, ruleError(rule, key, "условие 'from' указано повторно")
+			}
+			if value != "done" {
+				return nil, opts, ruleError(rule, key, "отсчёт 'from' возможен только от выполнения: from done")
+			}
+			opts.FromDone = true
+		default:
+			return nil, opts, ruleError(rule, key, fmt.Sprintf("неизвестное условие '%s'", key))
+		}
+	}
+
+	return parts[:i], opts, nil
+}
+
+func parseBaseRule(rule string, parts []string) (Rule, error) {
+	switch parts[0] {
+	case "d":
+		return parseDailyRule(rule, parts)
+	case "y":
+		if len(parts) != 1 {
+			return nil, ruleError(rule, "y", "неверный формат правила 'y'")
+		}
+		return YearlyRule{}, nil
+	case "w":
+		return parseWeeklyRule(rule, parts)
+	case "m":
+		return parseMonthlyRule(rule, parts)
+	case "mw":
+		return parseMonthWeekdayRule(rule, parts)
+	case "b":
+		return parseBusinessRule(rule, parts)
+	case "h":
+		return parseHourlyRule(rule, parts)
+	case "min":
+		return parseMinuteRule(rule, parts)
+	default:
+		return nil, ruleError(rule, "type", "неподдерживаемый формат правила")
+	}
+}
+
+func parseDailyRule(rule string, parts []string) (Rule, error) {
+	if len(parts) != 2 {
+		return nil, ruleError(rule, "d", "неверный формат правила 'd'")
+	}
+
+	days, err := strconv.Atoi(parts[1])
+	if err != nil || days < 1 || days > 400 {
+		return nil, ruleError(rule, "days", "некорректное количество дней")
+	}
+	return DailyRule{Days: days}, nil
+}
+
+// Наибольший интервал правил "w", "m" и "mw"
+const maxInterval = 100
+
+// Наибольшее число повторений в count и COUNT: чтобы найти следующую дату,
+// серию приходится перебирать от даты начала
+const maxCount = 10000
+
+// parseInterval отделяет от правила интервал вида "/N"
+func parseInterval(rule string, parts []string) ([]string, int, error) {
+	last := parts[len(parts)-1]
+	if len(parts) < 3 || !strings.HasPrefix(last, "/") {
+		return parts, 0, nil
+	}
+
+	interval, err := strconv.Atoi(last[1:])
+	if err != nil || interval < 1 || interval > maxInterval {
+		return nil, 0, ruleError(rule, "interval",
+			fmt.Sprintf("некорректный интервал %q: ожидается /N, где N от 1 до %d", last, maxInterval))
+	}
+	// "/1" — то же, что и без интервала: так правило совпадает со своей записью
+	if interval == 1 {
+		interval = 0
+	}
+	return parts[:len(parts)-1], interval, nil
+}
+
+func intervalString(interval int) string {
+	if interval > 1 {
+		return " /" + strconv.Itoa(interval)
+	}
+	return ""
+}
+
+func parseWeeklyRule(rule string, parts []string) (Rule, error) {
+	parts, interval, err := parseInterval(rule, parts)
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) != 2 {
+		return nil, ruleError(rule, "w", "неверный формат правила 'w'")
+	}
+
+	var days []int
+	for _, s := range strings.Split(parts[1], ",") {
+		d, err := strconv.Atoi(s)
+		if err != nil || d < 1 || d > 7 {
+			return nil, ruleError(rule, "days", "недопустимый день недели")
+		}
+		days = appendUnique(days, d)
+	}
+	sort.Ints(days)
+	return WeeklyRule{Days: days, Interval: interval}, nil
+}
+
+func parseMonthlyRule(rule string, parts []string) (Rule, error) {
+	parts, interval, err := parseInterval(rule, parts)
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil, ruleError(rule, "m", "неверный формат правила 'm'")
+	}
+
+	r := MonthlyRule{Interval: interval}
+	for _, s := range strings.Split(parts[1], ",") {
+		d, err := strconv.Atoi(s)
+		if err != nil || (d < -2 || d == 0 || d > 31) {
+			return nil, ruleError(rule, "days", "недопустимый день месяца")
+		}
+		r.Days = appendUnique(r.Days, d)
+	}
+	sort.Ints(r.Days)
+
+	if len(parts) > 2 {
+		months, err := parseMonths(parts[2])
+		if err != nil {
+			return nil, ruleError(rule, "months", err.Error())
+		}
+		r.Months = months
+	}
+
+	if !r.possible() {
+		return nil, ruleError(rule, "days", ErrNoDate.Error())
+	}
+	return r, nil
+}
+
+func parseMonthWeekdayRule(rule string, parts []string) (Rule, error) {
+	parts, interval, err := parseInterval(rule, parts)
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil, ruleError(rule, "mw", "неверный формат правила 'mw'")
+	}
+
+	r := MonthWeekdayRule{Interval: interval}
+	for _, s := range strings.Split(parts[1], ",") {
+		wd, err := parseNthWeekday(s)
+		if err != nil {
+			return nil, ruleError(rule, "days", err.Error())
+		}
+		if !slices.Contains(r.Days, wd) {
+			r.Days = append(r.Days, wd)
+		}
+	}
+	slices.SortFunc(r.Days, func(a, b NthWeekday) int {
+		if a.N != b.N {
+			return a.N - b.N
+		}
+		return a.Day - b.Day
+	})
+
+	if len(parts) > 2 {
+		months, err := parseMonths(parts[2])
+		if err != nil {
+			return nil, ruleError(rule, "months", err.Error())
+		}
+		r.Months = months
+	}
+	return r, nil
+}
+
+// parseNthWeekday разбирает элемент правила "mw" вида "N-D": "2-2" — второй вторник,
+// "-1-5" — последняя пятница
+func parseNthWeekday(s string) (NthWeekday, error) {
+	i := strings.LastIndex(s, "-")
+	if i <= 0 {
+		return NthWeekday{}, errors.New("неверный формат дня недели месяца")
+	}
+
+	n, err := strconv.Atoi(s[:i])
+	if err != nil || n == 0 || n < -5 || n > 5 {
+		return NthWeekday{}, errors.New("недопустимый номер недели месяца")
+	}
+	d, err := strconv.Atoi(s[i+1:])
+	if err != nil || d < 1 || d > 7 {
+		return NthWeekday{}, errors.New("недопустимый день недели")
+	}
+	return NthWeekday{N: n, Day: d}, nil
+}
+
+// parseMonths разбирает список месяцев правил "m" и "mw"
+func parseMonths(s string) ([]int, error) {
+	var months []int
+	for _, v := range strings.Split(s, ",") {
+		m, err := strconv.Atoi(v)
+		if err != nil || m < 1 || m > 12 {
+			return nil, errors.New("недопустимый месяц")
+		}
+		months = appendUnique(months, m)
+	}
+	sort.Ints(months)
+	return months, nil
+}
+
+// Next возвращает ближайшую дату по вложенному правилу, если она не позже Until
+// и входит в первые Count повторений
+func (b Bounded) Next(now, start time.Time) (time.Time, error) {
+	var date time.Time
+	var err error
+	if b.Count > 0 {
+		date, err = countNext(b.Rule, b.Count, now, start)
+	} else {
+		date, err = b.Rule.Next(now, start)
+	}
+	if err != nil {
+		return date, err
+	}
+	if !b.Until.IsZero() && afterNow(date, b.Until) {
+		return time.Time{}, ErrSeriesEnded
+	}
+	return date, nil
+}
+
+// countNext возвращает ближайшую дату правила rule, если она входит в первые
+// count повторений серии. Дата начала считается первым повторением, если
+// она не пропущена; пропущенные даты правило не возвращает, и они не считаются.
+func countNext(rule Rule, count int, now, start time.Time) (time.Time, error) {
+	next, err := rule.Next(now, start)
+	if err != nil {
+		return next, err
+	}
+
+	seen := 1
+	if isExcluded(rule, start) {
+		seen = 0
+	}
+	for date := start; date.Before(next); {
+		if date, err = rule.Next(date, start); err != nil {
+			return date, err
+		}
+		if seen++; seen > count {
+			return time.Time{}, ErrSeriesEnded
+		}
+	}
+	return next, nil
+}
+
+func (b Bounded) String() string {
+	s := b.Rule.String()
+	if !b.Until.IsZero() {
+		s += " until " + b.Until.Format(DateFormat)
+	}
+	if b.Count > 0 {
+		s += " count " + strconv.Itoa(b.Count)
+	}
+	return s
+}
+
+func (f FromDone) Next(now, startDate time.Time) (time.Time, error) {
+	return f.Rule.Next(now, startDate)
+}
+
+func (f FromDone) String() string {
+	return f.Rule.String() + " from done"
+}
+
+// WithExcept возвращает правило, в серии которого пропускаются даты dates.
+// Пропуски добавляются под условие окончания, чтобы пропущенная дата
+// не расходовала count: COUNT правила iCalendar для этого выносится в Bounded.
+func WithExcept(rule Rule, dates []time.Time) Rule {
+	if len(dates) == 0 {
+		return rule
+	}
+	switch r := rule.(type) {
+	case Bounded:
+		r.Rule = WithExcept(r.Rule, dates)
+		return r
+	case *RRule:
+		if r.Count > 0 {
+			return Bounded{Rule: Except{Rule: r.uncounted(), Dates: dates}, End: End{Count: r.Count}}
+		}
+	}
+	return Except{Rule: rule, Dates: dates}
+}
+
+// withoutCount возвращает правило без ограничения числа повторений
+func withoutCount(rule Rule) Rule {
+	switch r := rule.(type) {
+	case Bounded:
+		r.Rule = withoutCount(r.Rule)
+		r.Count = 0
+		return r
+	case Except:
+		r.Rule = withoutCount(r.Rule)
+		return r
+	case *RRule:
+		return r.uncounted()
+	}
+	return rule
+}
+
+// Next возвращает ближайшую дату по вложенному правилу, не входящую в пропуски
+func (e Except) Next(now, start time.Time) (time.Time, error) {
+	for i := 0; i <= len(e.Dates); i++ {
+		date, err := e.Rule.Next(now, start)
+		if err != nil || !e.excludes(date) {
+			return date, err
+		}
+		now = date
+	}
+	return time.Time{}, ErrNoDate
+}
+
+func (e Except) excludes(date time.Time) bool {
+	for _, d := range e.Dates {
+		if d.Equal(date) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e Except) String() string {
+	return e.Rule.String()
+}
+
+func (r DailyRule) String() string {
+	return "d " + strconv.Itoa(r.Days)
+}
+
+func (r WeeklyRule) String() string {
+	return "w " + joinInts(r.Days) + intervalString(r.Interval)
+}
+
+func (r MonthlyRule) String() string {
+	s := "m " + joinInts(r.Days)
+	if len(r.Months) > 0 {
+		s += " " + joinInts(r.Months)
+	}
+	return s + intervalString(r.Interval)
+}
+
+func (r MonthWeekdayRule) String() string {
+	days := make([]string, len(r.Days))
+	for i, wd := range r.Days {
+		days[i] = strconv.Itoa(wd.N) + "-" + strconv.Itoa(wd.Day)
+	}
+	s := "mw " + strings.Join(days, ",")
+	if len(r.Months) > 0 {
+		s += " " + joinInts(r.Months)
+	}
+	return s + intervalString(r.Interval)
+}
+
+func (YearlyRule) String() string {
+	return "y"
+}
+
+func joinInts(values []int) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = strconv.Itoa(v)
+	}
+	return strings.Join(s, ",")
+}
+
+func appendUnique(slice []int, item int) []int {
+	if contains(slice, item) {
+		return slice
+	}
+	return append(slice, item)
+}
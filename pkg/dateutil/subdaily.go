@@ -0,0 +1,130 @@
+package dateutil
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	TimeFormat     = "15:04"
+	DateTimeFormat = DateFormat + " " + TimeFormat
+)
+
+// Наибольший шаг правил "h" и "min": для более редких повторений есть "d"
+const (
+	maxHours   = 24 * 7
+	maxMinutes = 24 * 60
+)
+
+// HourlyRule — правило "h N": каждые N часов, считая от даты и времени начала
+type HourlyRule struct {
+	Hours int
+}
+
+// MinuteRule — правило "min N": каждые N минут, считая от даты и времени начала
+type MinuteRule struct {
+	Minutes int
+}
+
+func parseHourlyRule(rule string, parts []string) (Rule, error) {
+	if len(parts) != 2 {
+		return nil, ruleError(rule, "h", "неверный формат правила 'h'")
+	}
+
+	hours, err := strconv.Atoi(parts[1])
+	if err != nil || hours < 1 || hours > maxHours {
+		return nil, ruleError(rule, "hours", "некорректное количество часов")
+	}
+	return HourlyRule{Hours: hours}, nil
+}
+
+func parseMinuteRule(rule string, parts []string) (Rule, error) {
+	if len(parts) != 2 {
+		return nil, ruleError(rule, "min", "неверный формат правила 'min'")
+	}
+
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil || minutes < 1 || minutes > maxMinutes {
+		return nil, ruleError(rule, "minutes", "некорректное количество минут")
+	}
+	return MinuteRule{Minutes: minutes}, nil
+}
+
+// Правило "h": ближайшее время вида start + k*N часов, k >= 1, позже now
+func (r HourlyRule) Next(now, startDate time.Time) (time.Time, error) {
+	return nextStep(now, startDate, int64(r.Hours)*3600), nil
+}
+
+func (r HourlyRule) String() string {
+	return "h " + strconv.Itoa(r.Hours)
+}
+
+// Правило "min": ближайшее время вида start + k*N минут, k >= 1, позже now
+func (r MinuteRule) Next(now, startDate time.Time) (time.Time, error) {
+	return nextStep(now, startDate, int64(r.Minutes)*60), nil
+}
+
+func (r MinuteRule) String() string {
+	return "min " + strconv.Itoa(r.Minutes)
+}
+
+// nextStep возвращает ближайшее время вида start + k*step секунд, k >= 1, позже now.
+// now сравнивается по местному времени своего часового пояса.
+func nextStep(now, startDate time.Time, step int64) time.Time {
+	now = time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), now.Minute(), now.Second(),
+		now.Nanosecond(), startDate.Location())
+	steps := int64(1)
+	if !now.Before(startDate) {
+		steps = secondsBetween(startDate, now)/step + 1
+	}
+	return addSeconds(startDate, steps*step)
+}
+
+// IsSubDaily проверяет, что правило повторяет задачу несколько раз в сутки
+// и учитывает время, а не только дату
+func IsSubDaily(rule Rule) bool {
+	switch r := rule.(type) {
+	case HourlyRule, MinuteRule:
+		return true
+	case Bounded:
+		return IsSubDaily(r.Rule)
+	case Except:
+		return IsSubDaily(r.Rule)
+	case FromDone:
+		return IsSubDaily(r.Rule)
+	}
+	return false
+}
+
+// NextAt возвращает ближайшее по правилу время после now. Правила по дням
+// работают с датами, поэтому время суток берётся из даты начала.
+func NextAt(rule Rule, now, startDate time.Time) (time.Time, error) {
+	if IsSubDaily(rule) {
+		return rule.Next(now, startDate)
+	}
+
+	day := time.Date(startDate.Year(), startDate.Month(), startDate.Day(), 0, 0, 0, 0, startDate.Location())
+	offset := startDate.Sub(day)
+	// Правило сравнивает с now только даты. Сдвинув now на время задачи,
+	// получаем сравнение полного времени: задача на 10:00, запрошенная
+	// в 08:00, ещё приходится на сегодня.
+	next, err := rule.Next(now.Add(-offset), day)
+	if err != nil {
+		return next, err
+	}
+	return next.Add(offset), nil
+}
+
+// ParseDateTime разбирает дату "ГГГГММДД" или дату со временем "ГГГГММДД ЧЧ:ММ".
+// withTime сообщает, было ли указано время.
+func ParseDateTime(value string) (date time.Time, withTime bool, err error) {
+	layout := DateFormat
+	if withTime = len(value) > len(DateFormat); withTime {
+		layout = DateTimeFormat
+	}
+	if date, err = time.Parse(layout, value); err != nil {
+		return time.Time{}, withTime, fmt.Errorf("%w %q", ErrInvalidDate, value)
+	}
+	return date, withTime, nil
+}
@@ -1,37 +1,95 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
 
 	_ "modernc.org/sqlite"
 )
 
-const schema = `
-CREATE TABLE IF NOT EXISTS scheduler (
-    id INTEGER PRIMARY KEY AUTOINCREMENT,
-    date CHAR(8) NOT NULL DEFAULT "",
-    title VARCHAR(255),
-    comment TEXT,
-    repeat VARCHAR(128)
-);
-CREATE INDEX IF NOT EXISTS idx_date ON scheduler(date);
-`
+// Ошибки хранилища, которые проверяются через errors.Is
+var (
+	// ErrNotFound — задачи с таким идентификатором нет
+	ErrNotFound = errors.New("задача не найдена")
+	// ErrInvalidID — идентификатор задачи не является числом
+	ErrInvalidID = errors.New("некорректный ID задачи")
+	// ErrConflict — задачу успели изменить другим запросом
+	ErrConflict = errors.New("задача изменена другим запросом")
+	// ErrInvalidCursor — курсор страницы повреждён или получен для другого порядка
+	ErrInvalidCursor = errors.New("некорректный курсор страницы")
+	// ErrInvalidSort — неизвестный порядок сортировки
+	ErrInvalidSort = errors.New("неизвестный порядок сортировки")
+	// ErrFilterNotFound — сохранённого запроса с таким именем нет
+	ErrFilterNotFound = errors.New("сохранённый запрос не найден")
+	// ErrFilterExists — сохранённый запрос с таким именем уже есть
+	ErrFilterExists = errors.New("сохранённый запрос с таким именем уже есть")
+	// ErrTagNotFound — метки с таким идентификатором нет
+	ErrTagNotFound = errors.New("метка не найдена")
+	// ErrTagExists — метка с таким именем уже есть
+	ErrTagExists = errors.New("метка с таким именем уже есть")
+	// ErrHolidayNotFound — дня нет в производственном календаре
+	ErrHolidayNotFound = errors.New("день не найден в календаре")
+)
+
+// Сколько миллисекунд запрос ждёт, пока база занята другой записью
+const busyTimeout = 5000
+
+// DefaultQueryTimeout — ограничение времени одного запроса к базе по умолчанию
+const DefaultQueryTimeout = 5 * time.Second
 
 type Store struct {
-	db *sql.DB
+	db      *sql.DB
+	timeout time.Duration
 }
 
-func NewStore(dbFile string) (*Store, error) {
-	db, err := sql.Open("sqlite", dbFile)
+// Open открывает базу без применения миграций
+func Open(dbFile string) (*Store, error) {
+	// Параллельные запросы на запись ждут освобождения базы, а не получают
+	// сразу ошибку SQLITE_BUSY. Настройка задаётся для каждого соединения пула.
+	// Транзакции сразу занимают базу на запись (BEGIN IMMEDIATE): транзакция,
+	// которая сначала читает, а потом пишет, иначе может получить SQLITE_BUSY
+	// без ожидания, если параллельно начата такая же.
+	sep := "?"
+	if strings.Contains(dbFile, "?") {
+		sep = "&"
+	}
+	db, err := sql.Open("sqlite", dbFile+sep+"_pragma=busy_timeout("+strconv.Itoa(busyTimeout)+")&_txlock=immediate")
 	if err != nil {
 		return nil, err
 	}
+	return &Store{db: db, timeout: DefaultQueryTimeout}, nil
+}
 
-	if _, err = db.Exec(schema); err != nil {
+// NewStore открывает базу и приводит её схему к последней версии
+func NewStore(dbFile string) (*Store, error) {
+	store, err := Open(dbFile)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := store.Migrate(context.Background()); err != nil {
+		store.Close()
 		return nil, err
 	}
+	return store, nil
+}
 
-	return &Store{db: db}, nil
+// SetQueryTimeout задаёт ограничение времени одного запроса к базе,
+// 0 снимает ограничение
+func (s *Store) SetQueryTimeout(timeout time.Duration) {
+	s.timeout = timeout
+}
+
+// withTimeout ограничивает время запроса к базе, сохраняя отмену
+// родительского контекста
+func (s *Store) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.timeout)
 }
 
 func (s *Store) Close() error {
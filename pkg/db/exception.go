@@ -0,0 +1,86 @@
+package db
+
+import (
+	"context"
+	"fmt"
+)
+
+// Exceptions возвращает даты, пропущенные в серии повторений задачи
+func (s *Store) Exceptions(ctx context.Context, id string) ([]string, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	n, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return exceptions(ctx, s.db, n)
+}
+
+func exceptions(ctx context.Context, q querier, id int64) ([]string, error) {
+	rows, err := q.QueryContext(ctx, `SELECT date FROM exceptions WHERE task_id = ? ORDER BY date`, id)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса: %w", err)
+	}
+	defer rows.Close()
+
+	dates := make([]string, 0)
+	for rows.Next() {
+		var date string
+		if err := rows.Scan(&date); err != nil {
+			return nil, fmt.Errorf("ошибка чтения данных: %w", err)
+		}
+		dates = append(dates, date)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке результатов: %w", err)
+	}
+	return dates, nil
+}
+
+// SkipDate запоминает пропущенную дату серии — текущую дату задачи —
+// и переносит задачу на дату next. Пропуск не расходует счётчик оставшихся
+// повторений. Задача переносится, только если её версия всё ещё task.Version —
+// та, по которой вычислена дата next, а если version больше нуля, то и версия
+// из запроса должна с ней совпадать; иначе возвращается ErrConflict.
+// В task.Version записывается новая версия.
+func (s *Store) SkipDate(ctx context.Context, task *Task, version int64, next string) error {
+	if version > 0 && task.Version != version {
+		return ErrConflict
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `UPDATE scheduler SET date = ?, version = version + 1 WHERE id = ? AND version = ?`,
+		next, task.ID, task.Version)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления даты: %w", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("ошибка проверки обновления: %w", err)
+	}
+	if rowsAffected == 0 {
+		tx.Rollback()
+		return s.changed(ctx, task.ID)
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT OR IGNORE INTO exceptions (task_id, date) VALUES (?, ?)`, task.ID, task.Date)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения пропуска: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	task.Version++
+	return nil
+}
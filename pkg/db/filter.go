@@ -0,0 +1,102 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Filter — сохранённый запрос на языке taskql
+type Filter struct {
+	Name  string `json:"name"`
+	Query string `json:"query"`
+}
+
+// Filters возвращает сохранённые запросы по алфавиту
+func (s *Store) Filters(ctx context.Context) ([]Filter, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT name, query FROM filters ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса: %w", err)
+	}
+	defer rows.Close()
+
+	filters := make([]Filter, 0)
+	for rows.Next() {
+		var f Filter
+		if err := rows.Scan(&f.Name, &f.Query); err != nil {
+			return nil, fmt.Errorf("ошибка чтения данных: %w", err)
+		}
+		filters = append(filters, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке результатов: %w", err)
+	}
+	return filters, nil
+}
+
+func (s *Store) GetFilter(ctx context.Context, name string) (*Filter, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	f := Filter{Name: name}
+	err := s.db.QueryRowContext(ctx, `SELECT query FROM filters WHERE name = ?`, name).Scan(&f.Query)
+	if err == sql.ErrNoRows {
+		return nil, ErrFilterNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса: %w", err)
+	}
+	return &f, nil
+}
+
+// AddFilter сохраняет новый запрос. Если запрос с таким именем уже есть,
+// возвращается ErrFilterExists.
+func (s *Store) AddFilter(ctx context.Context, f Filter) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO filters (name, query) VALUES (?, ?) ON CONFLICT (name) DO NOTHING`, f.Name, f.Query)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения запроса: %w", err)
+	}
+	return affected(res, ErrFilterExists)
+}
+
+// UpdateFilter заменяет текст сохранённого запроса
+func (s *Store) UpdateFilter(ctx context.Context, f Filter) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, `UPDATE filters SET query = ? WHERE name = ?`, f.Query, f.Name)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления запроса: %w", err)
+	}
+	return affected(res, ErrFilterNotFound)
+}
+
+func (s *Store) DeleteFilter(ctx context.Context, name string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM filters WHERE name = ?`, name)
+	if err != nil {
+		return fmt.Errorf("ошибка удаления запроса: %w", err)
+	}
+	return affected(res, ErrFilterNotFound)
+}
+
+// affected возвращает errNone, если запрос не затронул ни одной строки
+func affected(res sql.Result, errNone error) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("ошибка проверки изменения: %w", err)
+	}
+	if n == 0 {
+		return errNone
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package db
+
+import (
+	"context"
+	"fmt"
+)
+
+// Holiday — день производственного календаря: праздник или рабочий выходной
+type Holiday struct {
+	Date    string
+	Title   string
+	Workday bool
+}
+
+// Holidays возвращает все дни производственного календаря по возрастанию даты
+func (s *Store) Holidays(ctx context.Context) ([]Holiday, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT date, title, workday FROM holidays ORDER BY date`)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса: %w", err)
+	}
+	defer rows.Close()
+
+	holidays := make([]Holiday, 0)
+	for rows.Next() {
+		var h Holiday
+		if err := rows.Scan(&h.Date, &h.Title, &h.Workday); err != nil {
+			return nil, fmt.Errorf("ошибка чтения данных: %w", err)
+		}
+		holidays = append(holidays, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке результатов: %w", err)
+	}
+	return holidays, nil
+}
+
+// SaveHolidays добавляет дни в календарь, заменяя уже существующие с той же датой.
+// При replace прежний календарь удаляется целиком.
+func (s *Store) SaveHolidays(ctx context.Context, holidays []Holiday, replace bool) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	if replace {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM holidays`); err != nil {
+			return fmt.Errorf("ошибка очистки календаря: %w", err)
+		}
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT OR REPLACE INTO holidays (date, title, workday) VALUES (?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("ошибка подготовки запроса: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, h := range holidays {
+		if _, err := stmt.ExecContext(ctx, h.Date, h.Title, h.Workday); err != nil {
+			return fmt.Errorf("ошибка сохранения дня %s: %w", h.Date, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DeleteHolidays удаляет день календаря с датой date, а при пустой дате — весь календарь
+func (s *Store) DeleteHolidays(ctx context.Context, date string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if date == "" {
+		_, err := s.db.ExecContext(ctx, `DELETE FROM holidays`)
+		if err != nil {
+			return fmt.Errorf("ошибка очистки календаря: %w", err)
+		}
+		return nil
+	}
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM holidays WHERE date = ?`, date)
+	if err != nil {
+		return fmt.Errorf("ошибка удаления дня: %w", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("ошибка проверки удаления: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrHolidayNotFound
+	}
+	return nil
+}
@@ -0,0 +1,643 @@
+package db
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore — хранилище в памяти с тем же поведением, что и Store.
+// Подходит для тестов и запуска без файла базы; безопасно
+// для одновременного использования из нескольких горутин.
+// Отменённый контекст проверяется перед каждой операцией.
+type MemoryStore struct {
+	mu         sync.RWMutex
+	lastID     int64
+	tasks      map[int64]Task
+	exceptions map[int64]map[string]bool
+	lastTagID  int64
+	tags       map[int64]Tag
+	taskTags   map[int64]map[int64]bool // метки задач по идентификатору задачи
+	filters    map[string]string
+	holidays   map[string]Holiday
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		tasks:      make(map[int64]Task),
+		exceptions: make(map[int64]map[string]bool),
+		tags:       make(map[int64]Tag),
+		taskTags:   make(map[int64]map[int64]bool),
+		filters:    make(map[string]string),
+		holidays:   make(map[string]Holiday),
+	}
+}
+
+func (s *MemoryStore) AddTask(ctx context.Context, task *Task) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastID++
+	stored := *task
+	stored.ID = s.lastID
+	stored.Version = 1
+	stored.Tags = nil
+	s.tasks[stored.ID] = stored
+	s.setTaskTags(stored.ID, task.Tags)
+	task.Tags = s.tagsOf(stored.ID)
+	return stored.ID, nil
+}
+
+// Tasks повторяет выборку Store: дата в виде ДД.ММ.ГГГГ ищется точно,
+// остальные слова — как начала слов заголовка и комментария без учёта
+// регистра. Вместо bm25 релевантность — число совпадений,
+// совпадения в заголовке считаются дважды.
+func (s *MemoryStore) Tasks(ctx context.Context, q TaskQuery) (*TaskPage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	page := &TaskPage{Tasks: make([]*Task, 0)}
+	match := func(*Task) (float64, bool) { return 0, true }
+	parsedDate, err := time.Parse("02.01.2006", q.Search)
+	search := err != nil && q.Search != ""
+	if err == nil {
+		date := parsedDate.Format(DateFormat)
+		match = func(t *Task) (float64, bool) { return 0, t.Date == date }
+	} else if search {
+		words := searchWords(q.Search)
+		if len(words) == 0 {
+			return page, nil
+		}
+		match = func(t *Task) (float64, bool) {
+			found := make(map[string]bool)
+			title := tokenize(t.Title)
+			comment := tokenize(t.Comment)
+			titleScore := matchWords(title, words, found)
+			commentScore := matchWords(comment, words, found)
+			if len(found) < len(words) {
+				return 0, false
+			}
+			if titleScore > 0 {
+				t.Snippet = snippet(t.Title, title, words)
+			} else {
+				t.Snippet = snippet(t.Comment, comment, words)
+			}
+			// Как у bm25, чем меньше значение, тем выше задача в выдаче
+			return -float64(2*titleScore + commentScore), true
+		}
+	}
+
+	key, err := q.order(search)
+	if err != nil {
+		return nil, err
+	}
+	var after []string
+	if q.Cursor != "" {
+		if after, err = decodeCursor(q, key); err != nil {
+			return nil, err
+		}
+	}
+
+	type entry struct {
+		task *Task
+		key  []string
+	}
+	var entries []entry
+	for _, t := range s.tasks {
+		task := t
+		task.Tags = s.tagsOf(task.ID)
+		if !q.matches(&task) {
+			continue
+		}
+		if score, ok := match(&task); ok {
+			entries = append(entries, entry{&task, key.values(&task, score)})
+		}
+	}
+	page.Total = len(entries)
+
+	direction := 1
+	if q.Desc {
+		direction = -1
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return direction*key.compare(entries[i].key, entries[j].key) < 0
+	})
+
+	for _, e := range entries {
+		if after != nil && direction*key.compare(e.key, after) <= 0 {
+			continue
+		}
+		if q.Limit > 0 && len(page.Tasks) == q.Limit {
+			page.NextCursor = cursor{Sort: key.name, Desc: q.Desc, Key: after}.encode()
+			break
+		}
+		page.Tasks = append(page.Tasks, e.task)
+		after = e.key
+	}
+	return page, nil
+}
+
+func (s *MemoryStore) GetTask(ctx context.Context, id string) (*Task, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	n, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	task, ok := s.tasks[n]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	task.Tags = s.tagsOf(n)
+	return &task, nil
+}
+
+func (s *MemoryStore) UpdateTask(ctx context.Context, task *Task) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.tasks[task.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	remaining := stored.Remaining
+	if stored.Repeat != task.Repeat {
+		remaining = task.Remaining
+	}
+	if task.Version > 0 && stored.Version != task.Version {
+		return ErrConflict
+	}
+	version := stored.Version + 1
+	stored = *task
+	stored.Remaining = remaining
+	stored.Version = version
+	stored.Tags = nil
+	s.tasks[task.ID] = stored
+	if task.Tags != nil {
+		s.setTaskTags(task.ID, task.Tags)
+	}
+	task.Version = version
+	task.Tags = s.tagsOf(task.ID)
+	return nil
+}
+
+func (s *MemoryStore) DeleteTask(ctx context.Context, id string, version int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	n, err := parseID(id)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.tasks[n]
+	if !ok {
+		return ErrNotFound
+	}
+	if version > 0 && stored.Version != version {
+		return ErrConflict
+	}
+	delete(s.tasks, n)
+	delete(s.exceptions, n)
+	delete(s.taskTags, n)
+	return nil
+}
+
+func (s *MemoryStore) UpdateDate(ctx context.Context, next string, nextTime string, task *Task) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.tasks[task.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if stored.Date != task.Date || stored.Time != task.Time {
+		return ErrConflict
+	}
+	stored.Date = next
+	stored.Time = nextTime
+	if stored.Remaining > 0 {
+		stored.Remaining--
+	}
+	stored.Version++
+	s.tasks[task.ID] = stored
+	return nil
+}
+
+func (s *MemoryStore) CompleteTask(ctx context.Context, id string, version int64, next NextFunc) (*Task, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	n, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.tasks[n]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if version > 0 && stored.Version != version {
+		return nil, ErrConflict
+	}
+
+	task := stored
+	date, nextTime, err := next(&task, s.exceptionDates(n))
+	if err != nil {
+		return nil, err
+	}
+	if date == "" {
+		delete(s.tasks, n)
+		delete(s.exceptions, n)
+		delete(s.taskTags, n)
+		return nil, nil
+	}
+
+	stored.Date = date
+	stored.Time = nextTime
+	if stored.Remaining > 0 {
+		stored.Remaining--
+	}
+	stored.Version++
+	s.tasks[n] = stored
+	stored.Tags = s.tagsOf(n)
+	return &stored, nil
+}
+
+func (s *MemoryStore) SkipDate(ctx context.Context, task *Task, version int64, next string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.tasks[task.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if stored.Version != task.Version || version > 0 && task.Version != version {
+		return ErrConflict
+	}
+	stored.Date = next
+	stored.Version++
+	task.Version = stored.Version
+	s.tasks[task.ID] = stored
+
+	if s.exceptions[task.ID] == nil {
+		s.exceptions[task.ID] = make(map[string]bool)
+	}
+	s.exceptions[task.ID][task.Date] = true
+	return nil
+}
+
+func (s *MemoryStore) Exceptions(ctx context.Context, id string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	n, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.exceptionDates(n), nil
+}
+
+// exceptionDates возвращает пропущенные даты задачи по порядку,
+// вызывается под блокировкой
+func (s *MemoryStore) exceptionDates(id int64) []string {
+	dates := make([]string, 0, len(s.exceptions[id]))
+	for date := range s.exceptions[id] {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+	return dates
+}
+
+// tagsOf возвращает имена меток задачи по алфавиту,
+// вызывается под блокировкой
+func (s *MemoryStore) tagsOf(id int64) []string {
+	names := make([]string, 0, len(s.taskTags[id]))
+	for tagID := range s.taskTags[id] {
+		names = append(names, s.tags[tagID].Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// setTaskTags заменяет метки задачи, создавая недостающие,
+// вызывается под блокировкой
+func (s *MemoryStore) setTaskTags(id int64, names []string) {
+	ids := make(map[int64]bool)
+	for _, name := range tagNames(names) {
+		tagID, ok := s.tagID(name)
+		if !ok {
+			s.lastTagID++
+			tagID = s.lastTagID
+			s.tags[tagID] = Tag{ID: tagID, Name: name}
+		}
+		ids[tagID] = true
+	}
+	s.taskTags[id] = ids
+}
+
+// tagID ищет метку по имени, вызывается под блокировкой
+func (s *MemoryStore) tagID(name string) (int64, bool) {
+	for id, tag := range s.tags {
+		if tag.Name == name {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// touchTagged увеличивает версии задач с меткой, вызывается под блокировкой
+func (s *MemoryStore) touchTagged(tagID int64) {
+	for id, tags := range s.taskTags {
+		if tags[tagID] {
+			task := s.tasks[id]
+			task.Version++
+			s.tasks[id] = task
+		}
+	}
+}
+
+// tagged возвращает число задач с меткой, вызывается под блокировкой
+func (s *MemoryStore) tagged(tagID int64) int {
+	n := 0
+	for _, tags := range s.taskTags {
+		if tags[tagID] {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *MemoryStore) Tags(ctx context.Context) ([]Tag, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tags := make([]Tag, 0, len(s.tags))
+	for id, tag := range s.tags {
+		tag.Tasks = s.tagged(id)
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	return tags, nil
+}
+
+func (s *MemoryStore) AddTag(ctx context.Context, tag *Tag) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tag.Name = TagName(tag.Name)
+	if _, ok := s.tagID(tag.Name); ok {
+		return ErrTagExists
+	}
+	s.lastTagID++
+	tag.ID = s.lastTagID
+	tag.Tasks = 0
+	s.tags[tag.ID] = Tag{ID: tag.ID, Name: tag.Name, Color: tag.Color}
+	return nil
+}
+
+func (s *MemoryStore) UpdateTag(ctx context.Context, tag *Tag) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.tags[tag.ID]
+	if !ok {
+		return ErrTagNotFound
+	}
+	tag.Name = TagName(tag.Name)
+	if stored.Name != tag.Name {
+		if _, taken := s.tagID(tag.Name); taken {
+			return ErrTagExists
+		}
+		s.touchTagged(tag.ID)
+	}
+	s.tags[tag.ID] = Tag{ID: tag.ID, Name: tag.Name, Color: tag.Color}
+	tag.Tasks = s.tagged(tag.ID)
+	return nil
+}
+
+func (s *MemoryStore) MergeTags(ctx context.Context, from, into int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, fromOK := s.tags[from]
+	_, intoOK := s.tags[into]
+	if !fromOK || !intoOK {
+		return ErrTagNotFound
+	}
+	if from == into {
+		return nil
+	}
+	s.touchTagged(from)
+	for _, tags := range s.taskTags {
+		if tags[from] {
+			delete(tags, from)
+			tags[into] = true
+		}
+	}
+	delete(s.tags, from)
+	return nil
+}
+
+func (s *MemoryStore) DeleteTag(ctx context.Context, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.tags[id]; !ok {
+		return ErrTagNotFound
+	}
+	s.touchTagged(id)
+	for _, tags := range s.taskTags {
+		delete(tags, id)
+	}
+	delete(s.tags, id)
+	return nil
+}
+
+func (s *MemoryStore) Filters(ctx context.Context) ([]Filter, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	filters := make([]Filter, 0, len(s.filters))
+	for name, query := range s.filters {
+		filters = append(filters, Filter{Name: name, Query: query})
+	}
+	sort.Slice(filters, func(i, j int) bool { return filters[i].Name < filters[j].Name })
+	return filters, nil
+}
+
+func (s *MemoryStore) GetFilter(ctx context.Context, name string) (*Filter, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	query, ok := s.filters[name]
+	if !ok {
+		return nil, ErrFilterNotFound
+	}
+	return &Filter{Name: name, Query: query}, nil
+}
+
+func (s *MemoryStore) AddFilter(ctx context.Context, f Filter) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.filters[f.Name]; ok {
+		return ErrFilterExists
+	}
+	s.filters[f.Name] = f.Query
+	return nil
+}
+
+func (s *MemoryStore) UpdateFilter(ctx context.Context, f Filter) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.filters[f.Name]; !ok {
+		return ErrFilterNotFound
+	}
+	s.filters[f.Name] = f.Query
+	return nil
+}
+
+func (s *MemoryStore) DeleteFilter(ctx context.Context, name string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.filters[name]; !ok {
+		return ErrFilterNotFound
+	}
+	delete(s.filters, name)
+	return nil
+}
+
+func (s *MemoryStore) Holidays(ctx context.Context) ([]Holiday, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	holidays := make([]Holiday, 0, len(s.holidays))
+	for _, h := range s.holidays {
+		holidays = append(holidays, h)
+	}
+	sort.Slice(holidays, func(i, j int) bool { return holidays[i].Date < holidays[j].Date })
+	return holidays, nil
+}
+
+func (s *MemoryStore) SaveHolidays(ctx context.Context, holidays []Holiday, replace bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if replace {
+		s.holidays = make(map[string]Holiday)
+	}
+	for _, h := range holidays {
+		s.holidays[h.Date] = h
+	}
+	return nil
+}
+
+func (s *MemoryStore) DeleteHolidays(ctx context.Context, date string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if date == "" {
+		s.holidays = make(map[string]Holiday)
+		return nil
+	}
+	if _, ok := s.holidays[date]; !ok {
+		return ErrHolidayNotFound
+	}
+	delete(s.holidays, date)
+	return nil
+}
@@ -0,0 +1,175 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SQL-миграции лежат в migrations/ с именами вида 0001_init.sql:
+// номер задаёт порядок применения, остаток имени — описание
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migration — одна версия схемы базы. Миграция выполняет либо SQL
+// из файла, либо функцию Up, когда одного SQL недостаточно.
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+	Up      func(ctx context.Context, tx *sql.Tx) error
+}
+
+// MigrationStatus — миграция и признак того, что она уже применена
+type MigrationStatus struct {
+	Migration
+	Applied bool
+}
+
+// Миграции на Go. Столбцы добавляются с проверкой: в базах, созданных
+// до появления миграций, они уже могут быть.
+var goMigrations = []Migration{
+	{Version: 3, Name: "remaining", Up: func(ctx context.Context, tx *sql.Tx) error {
+		return ensureColumn(ctx, tx, "scheduler", "remaining", "INTEGER NOT NULL DEFAULT 0")
+	}},
+	{Version: 5, Name: "time", Up: func(ctx context.Context, tx *sql.Tx) error {
+		return ensureColumn(ctx, tx, "scheduler", "time", `CHAR(5) NOT NULL DEFAULT ""`)
+	}},
+}
+
+// migrations возвращает все миграции, упорядоченные по версии
+func migrations() ([]Migration, error) {
+	files, err := fs.Glob(migrationFiles, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	list := append([]Migration(nil), goMigrations...)
+	for _, file := range files {
+		name := strings.TrimSuffix(path.Base(file), ".sql")
+		num, title, ok := strings.Cut(name, "_")
+		version, err := strconv.Atoi(num)
+		if !ok || err != nil || version < 1 {
+			return nil, fmt.Errorf("некорректное имя миграции %q", file)
+		}
+		data, err := migrationFiles.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, Migration{Version: version, Name: title, SQL: string(data)})
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	for i := 1; i < len(list); i++ {
+		if list[i].Version == list[i-1].Version {
+			return nil, fmt.Errorf("версия миграции %d указана дважды", list[i].Version)
+		}
+	}
+	return list, nil
+}
+
+// version возвращает текущую версию схемы из PRAGMA user_version
+func (s *Store) version(ctx context.Context) (int, error) {
+	var v int
+	err := s.db.QueryRowContext(ctx, `PRAGMA user_version`).Scan(&v)
+	return v, err
+}
+
+// Migrations возвращает список миграций с отметкой о применении
+func (s *Store) Migrations(ctx context.Context) ([]MigrationStatus, error) {
+	list, err := migrations()
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.version(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения версии схемы: %v", err)
+	}
+
+	statuses := make([]MigrationStatus, 0, len(list))
+	for _, m := range list {
+		statuses = append(statuses, MigrationStatus{Migration: m, Applied: m.Version <= current})
+	}
+	return statuses, nil
+}
+
+// Migrate применяет ещё не выполненные миграции по порядку. Каждая миграция
+// выполняется в своей транзакции вместе с записью новой версии схемы,
+// поэтому прерванная миграция не оставляет базу в промежуточном состоянии.
+func (s *Store) Migrate(ctx context.Context) ([]Migration, error) {
+	statuses, err := s.Migrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, m := range statuses {
+		if m.Applied {
+			continue
+		}
+		if err := s.apply(ctx, m.Migration); err != nil {
+			return applied, fmt.Errorf("ошибка миграции %04d_%s: %v", m.Version, m.Name, err)
+		}
+		applied = append(applied, m.Migration)
+	}
+	return applied, nil
+}
+
+func (s *Store) apply(ctx context.Context, m Migration) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if m.Up != nil {
+		err = m.Up(ctx, tx)
+	} else {
+		_, err = tx.ExecContext(ctx, m.SQL)
+	}
+	if err != nil {
+		return err
+	}
+	// PRAGMA не поддерживает параметры запроса, версия — число из списка миграций
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", m.Version)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ensureColumn добавляет столбец в таблицу, если его там ещё нет
+func ensureColumn(ctx context.Context, tx *sql.Tx, table, column, definition string) error {
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, typ        string
+			dflt             sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	_, err = tx.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
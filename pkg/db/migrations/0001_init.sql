@@ -0,0 +1,9 @@
+-- Исходная таблица задач
+CREATE TABLE IF NOT EXISTS scheduler (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    date CHAR(8) NOT NULL DEFAULT "",
+    title VARCHAR(255),
+    comment TEXT,
+    repeat VARCHAR(128)
+);
+CREATE INDEX IF NOT EXISTS idx_date ON scheduler(date);
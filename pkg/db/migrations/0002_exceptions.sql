@@ -0,0 +1,10 @@
+-- Даты, пропущенные в сериях повторений
+CREATE TABLE IF NOT EXISTS exceptions (
+    task_id INTEGER NOT NULL,
+    date CHAR(8) NOT NULL,
+    PRIMARY KEY (task_id, date)
+);
+CREATE TRIGGER IF NOT EXISTS scheduler_delete_exceptions AFTER DELETE ON scheduler
+BEGIN
+    DELETE FROM exceptions WHERE task_id = OLD.id;
+END;
@@ -0,0 +1,6 @@
+-- Производственный календарь
+CREATE TABLE IF NOT EXISTS holidays (
+    date CHAR(8) PRIMARY KEY,
+    title VARCHAR(255) NOT NULL DEFAULT "",
+    workday INTEGER NOT NULL DEFAULT 0
+);
@@ -0,0 +1,3 @@
+-- Версия задачи для условных запросов (ETag / If-Match),
+-- увеличивается при каждом изменении задачи
+ALTER TABLE scheduler ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,24 @@
+-- Полнотекстовый индекс по заголовку и комментарию. Токенизатор unicode61
+-- приводит к нижнему регистру и кириллицу; диакритика не снимается,
+-- чтобы "й" не совпадала с "и". Индекс хранит только токены,
+-- текст берётся из scheduler и поддерживается триггерами.
+CREATE VIRTUAL TABLE scheduler_fts USING fts5(
+    title, comment,
+    content = 'scheduler', content_rowid = 'id',
+    tokenize = 'unicode61 remove_diacritics 0'
+);
+INSERT INTO scheduler_fts(scheduler_fts) VALUES ('rebuild');
+
+CREATE TRIGGER scheduler_fts_insert AFTER INSERT ON scheduler
+BEGIN
+    INSERT INTO scheduler_fts(rowid, title, comment) VALUES (NEW.id, NEW.title, NEW.comment);
+END;
+CREATE TRIGGER scheduler_fts_delete AFTER DELETE ON scheduler
+BEGIN
+    INSERT INTO scheduler_fts(scheduler_fts, rowid, title, comment) VALUES ('delete', OLD.id, OLD.title, OLD.comment);
+END;
+CREATE TRIGGER scheduler_fts_update AFTER UPDATE OF title, comment ON scheduler
+BEGIN
+    INSERT INTO scheduler_fts(scheduler_fts, rowid, title, comment) VALUES ('delete', OLD.id, OLD.title, OLD.comment);
+    INSERT INTO scheduler_fts(rowid, title, comment) VALUES (NEW.id, NEW.title, NEW.comment);
+END;
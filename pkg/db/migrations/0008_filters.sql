@@ -0,0 +1,5 @@
+-- Сохранённые запросы на языке taskql
+CREATE TABLE filters (
+    name VARCHAR(64) PRIMARY KEY,
+    query TEXT NOT NULL
+);
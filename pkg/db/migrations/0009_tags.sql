@@ -0,0 +1,20 @@
+-- Метки задач: справочник меток и связь задач с метками
+CREATE TABLE tags (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(64) NOT NULL UNIQUE,
+    color CHAR(7) NOT NULL DEFAULT ''
+);
+CREATE TABLE task_tags (
+    task_id INTEGER NOT NULL,
+    tag_id INTEGER NOT NULL,
+    PRIMARY KEY (task_id, tag_id)
+);
+CREATE INDEX task_tags_tag ON task_tags (tag_id);
+CREATE TRIGGER scheduler_delete_tags AFTER DELETE ON scheduler
+BEGIN
+    DELETE FROM task_tags WHERE task_id = OLD.id;
+END;
+CREATE TRIGGER tags_delete_tasks AFTER DELETE ON tags
+BEGIN
+    DELETE FROM task_tags WHERE tag_id = OLD.id;
+END;
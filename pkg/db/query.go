@@ -0,0 +1,214 @@
+package db
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"go1f/pkg/taskql"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Порядок задач в выборке
+const (
+	SortDate      = "date"      // по дате и времени
+	SortTitle     = "title"     // по заголовку
+	SortCreated   = "created"   // по времени создания
+	SortRelevance = "relevance" // по релевантности поиска, затем по дате
+)
+
+// TaskQuery — параметры выборки задач. Условия объединяются через "и".
+type TaskQuery struct {
+	Search     string   // дата ДД.ММ.ГГГГ или слова для поиска
+	From       string   // дата ГГГГММДД, с которой выбираются задачи
+	To         string   // дата ГГГГММДД, по которую выбираются задачи
+	Repeating  *bool    // только повторяющиеся или только разовые задачи
+	HasComment *bool    // только задачи с комментарием или без него
+	Tags       []string // только задачи со всеми этими метками
+	Filter     *taskql.Query
+	Limit      int    // размер страницы, 0 — без ограничения
+	Sort       string // Sort*; по умолчанию по релевантности при поиске, иначе по дате
+	Desc       bool   // в обратном порядке
+	Cursor     string // курсор из TaskPage.NextCursor, с которого начинается страница
+}
+
+// TaskPage — страница выборки задач
+type TaskPage struct {
+	Tasks      []*Task
+	Total      int    // сколько всего задач подходит под условия выборки
+	NextCursor string // курсор следующей страницы, пустой на последней
+}
+
+// where возвращает условия выборки, кроме поиска, для запроса к SQLite
+func (q TaskQuery) where() ([]string, []interface{}) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	if q.From != "" {
+		where = append(where, "scheduler.date >= ?")
+		args = append(args, q.From)
+	}
+	if q.To != "" {
+		where = append(where, "scheduler.date <= ?")
+		args = append(args, q.To)
+	}
+	if q.Repeating != nil {
+		where = append(where, "scheduler.repeat "+notEmpty(*q.Repeating))
+	}
+	if q.HasComment != nil {
+		where = append(where, "scheduler.comment "+notEmpty(*q.HasComment))
+	}
+	for _, tag := range q.Tags {
+		where = append(where, taskql.TaggedSQL)
+		args = append(args, TagName(tag))
+	}
+	if q.Filter != nil {
+		filter, filterArgs := q.Filter.Where()
+		where = append(where, filter...)
+		args = append(args, filterArgs...)
+	}
+	return where, args
+}
+
+// notEmpty возвращает условие на пустую или непустую строку
+func notEmpty(yes bool) string {
+	if yes {
+		return "!= ''"
+	}
+	return "= ''"
+}
+
+// matches проверяет те же условия, что и where, для задачи в памяти
+func (q TaskQuery) matches(t *Task) bool {
+	switch {
+	case q.From != "" && t.Date < q.From,
+		q.To != "" && t.Date > q.To,
+		q.Repeating != nil && (t.Repeat != "") != *q.Repeating,
+		q.HasComment != nil && (t.Comment != "") != *q.HasComment:
+		return false
+	}
+	for _, tag := range q.Tags {
+		if !slices.Contains(t.Tags, TagName(tag)) {
+			return false
+		}
+	}
+	return q.Filter == nil ||
+		q.Filter.Match(taskql.Fields{Title: t.Title, Comment: t.Comment, Repeat: t.Repeat, Date: t.Date, Tags: t.Tags})
+}
+
+// sortKey — ключ сортировки: столбцы, однозначно задающие порядок задач
+type sortKey struct {
+	name    string
+	columns []string
+}
+
+var sortKeys = map[string]sortKey{
+	SortDate:      {SortDate, []string{"date", "time", "id"}},
+	SortTitle:     {SortTitle, []string{"title", "id"}},
+	SortCreated:   {SortCreated, []string{"id"}},
+	SortRelevance: {SortRelevance, []string{"score", "date", "time", "id"}},
+}
+
+// order возвращает ключ сортировки выборки
+func (q TaskQuery) order(search bool) (sortKey, error) {
+	name := q.Sort
+	if name == "" {
+		name = SortDate
+		if search {
+			name = SortRelevance
+		}
+	}
+	key, ok := sortKeys[name]
+	if !ok {
+		return sortKey{}, ErrInvalidSort
+	}
+	return key, nil
+}
+
+// values возвращает значения ключа сортировки для задачи
+func (k sortKey) values(t *Task, score float64) []string {
+	values := make([]string, 0, len(k.columns))
+	for _, column := range k.columns {
+		switch column {
+		case "score":
+			values = append(values, strconv.FormatFloat(score, 'g', -1, 64))
+		case "date":
+			values = append(values, t.Date)
+		case "time":
+			values = append(values, t.Time)
+		case "title":
+			values = append(values, t.Title)
+		case "id":
+			values = append(values, strconv.FormatInt(t.ID, 10))
+		}
+	}
+	return values
+}
+
+// compare сравнивает значения ключа сортировки a и b, как это делает SQLite:
+// числа — как числа, строки — побайтно
+func (k sortKey) compare(a, b []string) int {
+	for i, column := range k.columns {
+		var c int
+		switch column {
+		case "score":
+			x, _ := strconv.ParseFloat(a[i], 64)
+			y, _ := strconv.ParseFloat(b[i], 64)
+			c = cmp.Compare(x, y)
+		case "id":
+			x, _ := strconv.ParseInt(a[i], 10, 64)
+			y, _ := strconv.ParseInt(b[i], 10, 64)
+			c = cmp.Compare(x, y)
+		default:
+			c = strings.Compare(a[i], b[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// cursor — позиция последней задачи страницы. Вместе с ключом сохраняется
+// порядок выборки: курсор другого порядка к выборке не подходит.
+type cursor struct {
+	Sort string   `json:"s"`
+	Desc bool     `json:"d,omitempty"`
+	Key  []string `json:"k"`
+}
+
+// encode возвращает непрозрачную строку курсора
+func (c cursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor разбирает курсор выборки q с ключом сортировки key.
+// Числовые значения ключа проверяются, поэтому их можно сравнивать как числа.
+func decodeCursor(q TaskQuery, key sortKey) ([]string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort != key.name || c.Desc != q.Desc || len(c.Key) != len(key.columns) {
+		return nil, ErrInvalidCursor
+	}
+	for i, column := range key.columns {
+		switch column {
+		case "score":
+			_, err = strconv.ParseFloat(c.Key[i], 64)
+		case "id":
+			_, err = strconv.ParseInt(c.Key[i], 10, 64)
+		}
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return c.Key, nil
+}
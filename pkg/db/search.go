@@ -0,0 +1,135 @@
+package db
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Границы найденных слов во фрагменте Task.Snippet. Управляющие символы
+// не встречаются в тексте задач, поэтому API может экранировать фрагмент
+// и уже потом заменить их разметкой.
+const (
+	SnippetStart = "\x02"
+	SnippetEnd   = "\x03"
+)
+
+// Сколько слов попадает во фрагмент с найденными словами
+const snippetTokens = 12
+
+// Многоточие на месте обрезанного текста во фрагменте
+const snippetEllipsis = "…"
+
+// token — слово текста и его границы в байтах
+type token struct {
+	word       string // слово в нижнем регистре
+	start, end int
+}
+
+// tokenize разбивает текст на слова так же, как токенизатор unicode61
+// полнотекстового индекса: слово — последовательность букв и цифр
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text {
+		inWord := unicode.IsLetter(r) || unicode.IsNumber(r)
+		switch {
+		case inWord && start < 0:
+			start = i
+		case !inWord && start >= 0:
+			tokens = append(tokens, token{strings.ToLower(text[start:i]), start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{strings.ToLower(text[start:]), start, len(text)})
+	}
+	return tokens
+}
+
+// searchWords возвращает слова поискового запроса
+func searchWords(search string) []string {
+	tokens := tokenize(search)
+	words := make([]string, 0, len(tokens))
+	for _, t := range tokens {
+		words = append(words, t.word)
+	}
+	return words
+}
+
+// matchQuery составляет запрос FTS5: задача должна содержать слова,
+// начинающиеся с каждого из слов поиска. Слова берутся в кавычки,
+// поэтому операторы FTS5 в строке поиска не действуют.
+func matchQuery(words []string) string {
+	terms := make([]string, 0, len(words))
+	for _, w := range words {
+		terms = append(terms, `"`+strings.ReplaceAll(w, `"`, `""`)+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
+
+// matches проверяет, начинается ли слово текста с одного из слов поиска
+func matches(word string, words []string) bool {
+	for _, w := range words {
+		if strings.HasPrefix(word, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchWords возвращает, сколько слов текста начинается с одного из слов поиска,
+// и отмечает найденные слова поиска в found
+func matchWords(tokens []token, words []string, found map[string]bool) int {
+	n := 0
+	for _, t := range tokens {
+		if !matches(t.word, words) {
+			continue
+		}
+		n++
+		for _, w := range words {
+			if strings.HasPrefix(t.word, w) {
+				found[w] = true
+			}
+		}
+	}
+	return n
+}
+
+// snippet возвращает фрагмент текста вокруг первого найденного слова
+// с отмеченными словами поиска, как функция snippet() в FTS5
+func snippet(text string, tokens []token, words []string) string {
+	first := 0
+	for i, t := range tokens {
+		if matches(t.word, words) {
+			first = i
+			break
+		}
+	}
+	from := first
+	if from+snippetTokens > len(tokens) {
+		from = max(len(tokens)-snippetTokens, 0)
+	}
+	to := min(from+snippetTokens, len(tokens))
+
+	var b strings.Builder
+	pos := 0
+	if from > 0 {
+		b.WriteString(snippetEllipsis)
+		pos = tokens[from].start
+	}
+	for _, t := range tokens[from:to] {
+		b.WriteString(text[pos:t.start])
+		if matches(t.word, words) {
+			b.WriteString(SnippetStart + text[t.start:t.end] + SnippetEnd)
+		} else {
+			b.WriteString(text[t.start:t.end])
+		}
+		pos = t.end
+	}
+	if to < len(tokens) {
+		b.WriteString(snippetEllipsis)
+	} else {
+		b.WriteString(text[pos:])
+	}
+	return b.String()
+}
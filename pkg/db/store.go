@@ -0,0 +1,42 @@
+package db
+
+import "context"
+
+// TaskStore — хранилище задач, пропущенных дат, меток, сохранённых запросов
+// и производственного календаря.
+// Его реализуют Store на SQLite и MemoryStore в памяти. Все методы
+// прекращают работу, когда отменён контекст запроса, и возвращают ошибку,
+// для которой errors.Is находит context.Canceled или context.DeadlineExceeded.
+type TaskStore interface {
+	AddTask(ctx context.Context, task *Task) (int64, error)
+	Tasks(ctx context.Context, q TaskQuery) (*TaskPage, error)
+	GetTask(ctx context.Context, id string) (*Task, error)
+	UpdateTask(ctx context.Context, task *Task) error
+	DeleteTask(ctx context.Context, id string, version int64) error
+	UpdateDate(ctx context.Context, next string, nextTime string, task *Task) error
+	CompleteTask(ctx context.Context, id string, version int64, next NextFunc) (*Task, error)
+
+	SkipDate(ctx context.Context, task *Task, version int64, next string) error
+	Exceptions(ctx context.Context, id string) ([]string, error)
+
+	Tags(ctx context.Context) ([]Tag, error)
+	AddTag(ctx context.Context, tag *Tag) error
+	UpdateTag(ctx context.Context, tag *Tag) error
+	MergeTags(ctx context.Context, from, into int64) error
+	DeleteTag(ctx context.Context, id int64) error
+
+	Filters(ctx context.Context) ([]Filter, error)
+	GetFilter(ctx context.Context, name string) (*Filter, error)
+	AddFilter(ctx context.Context, f Filter) error
+	UpdateFilter(ctx context.Context, f Filter) error
+	DeleteFilter(ctx context.Context, name string) error
+
+	Holidays(ctx context.Context) ([]Holiday, error)
+	SaveHolidays(ctx context.Context, holidays []Holiday, replace bool) error
+	DeleteHolidays(ctx context.Context, date string) error
+}
+
+var (
+	_ TaskStore = (*Store)(nil)
+	_ TaskStore = (*MemoryStore)(nil)
+)
@@ -0,0 +1,267 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Tag — метка задачи
+type Tag struct {
+	ID    int64  `json:"id,string"`
+	Name  string `json:"name"`
+	Color string `json:"color"` // цвет #rrggbb, пустой — без цвета
+	Tasks int    `json:"tasks"` // сколько задач с этой меткой
+}
+
+// TagName приводит имя метки к хранимому виду: без пробелов по краям
+// и "#" в начале, в нижнем регистре
+func TagName(name string) string {
+	return strings.ToLower(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(name), "#")))
+}
+
+// tagNames приводит имена меток задачи к хранимому виду, убирает пустые
+// и повторяющиеся и упорядочивает их
+func tagNames(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	list := make([]string, 0, len(names))
+	for _, name := range names {
+		name = TagName(name)
+		if name != "" && !seen[name] {
+			seen[name] = true
+			list = append(list, name)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
+
+// Tags возвращает метки по алфавиту с числом задач у каждой
+func (s *Store) Tags(ctx context.Context) ([]Tag, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT tags.id, tags.name, tags.color, count(task_tags.task_id)
+		FROM tags LEFT JOIN task_tags ON task_tags.tag_id = tags.id
+		GROUP BY tags.id ORDER BY tags.name`)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса: %w", err)
+	}
+	defer rows.Close()
+
+	tags := make([]Tag, 0)
+	for rows.Next() {
+		var t Tag
+		if err := rows.Scan(&t.ID, &t.Name, &t.Color, &t.Tasks); err != nil {
+			return nil, fmt.Errorf("ошибка чтения данных: %w", err)
+		}
+		tags = append(tags, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при обработке результатов: %w", err)
+	}
+	return tags, nil
+}
+
+// AddTag создаёт метку и записывает её идентификатор в tag.ID.
+// Если метка с таким именем уже есть, возвращается ErrTagExists.
+func (s *Store) AddTag(ctx context.Context, tag *Tag) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tag.Name = TagName(tag.Name)
+	err := s.db.QueryRowContext(ctx,
+		`INSERT INTO tags (name, color) VALUES (?, ?) ON CONFLICT (name) DO NOTHING RETURNING id`,
+		tag.Name, tag.Color).Scan(&tag.ID)
+	if err == sql.ErrNoRows {
+		return ErrTagExists
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения метки: %w", err)
+	}
+	tag.Tasks = 0
+	return nil
+}
+
+// UpdateTag переименовывает метку tag.ID и меняет её цвет. Если имя
+// занято другой меткой, возвращается ErrTagExists. Версии задач с меткой
+// при переименовании растут, как при любом изменении задачи.
+func (s *Store) UpdateTag(ctx context.Context, tag *Tag) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	tag.Name = TagName(tag.Name)
+	var name string
+	err = tx.QueryRowContext(ctx, `SELECT name FROM tags WHERE id = ?`, tag.ID).Scan(&name)
+	if err == sql.ErrNoRows {
+		return ErrTagNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка запроса: %w", err)
+	}
+	if name != tag.Name {
+		var taken bool
+		err = tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM tags WHERE name = ?)`, tag.Name).Scan(&taken)
+		if err != nil {
+			return fmt.Errorf("ошибка проверки метки: %w", err)
+		}
+		if taken {
+			return ErrTagExists
+		}
+		if err := touchTagged(ctx, tx, tag.ID); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE tags SET name = ?, color = ? WHERE id = ?`,
+		tag.Name, tag.Color, tag.ID); err != nil {
+		return fmt.Errorf("ошибка обновления метки: %w", err)
+	}
+	err = tx.QueryRowContext(ctx, `SELECT count(*) FROM task_tags WHERE tag_id = ?`, tag.ID).Scan(&tag.Tasks)
+	if err != nil {
+		return fmt.Errorf("ошибка подсчёта задач: %w", err)
+	}
+	return tx.Commit()
+}
+
+// MergeTags переносит метку from на задачи метки into и удаляет from
+func (s *Store) MergeTags(ctx context.Context, from, into int64) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	var found int
+	err = tx.QueryRowContext(ctx, `SELECT count(*) FROM tags WHERE id IN (?, ?)`, from, into).Scan(&found)
+	if err != nil {
+		return fmt.Errorf("ошибка проверки метки: %w", err)
+	}
+	if from == into {
+		if found == 0 {
+			return ErrTagNotFound
+		}
+		return nil
+	}
+	if found < 2 {
+		return ErrTagNotFound
+	}
+
+	if err := touchTagged(ctx, tx, from); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO task_tags (task_id, tag_id)
+		SELECT task_id, ? FROM task_tags WHERE tag_id = ? ON CONFLICT DO NOTHING`, into, from); err != nil {
+		return fmt.Errorf("ошибка объединения меток: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM tags WHERE id = ?`, from); err != nil {
+		return fmt.Errorf("ошибка удаления метки: %w", err)
+	}
+	return tx.Commit()
+}
+
+// DeleteTag удаляет метку и снимает её со всех задач
+func (s *Store) DeleteTag(ctx context.Context, id int64) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := touchTagged(ctx, tx, id); err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM tags WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("ошибка удаления метки: %w", err)
+	}
+	if err := affected(res, ErrTagNotFound); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// touchTagged увеличивает версии задач с меткой tagID: для клиентов
+// изменение меток задачи — такое же изменение, как и правка её полей
+func touchTagged(ctx context.Context, tx *sql.Tx, tagID int64) error {
+	_, err := tx.ExecContext(ctx, `UPDATE scheduler SET version = version + 1
+		WHERE id IN (SELECT task_id FROM task_tags WHERE tag_id = ?)`, tagID)
+	if err != nil {
+		return fmt.Errorf("ошибка обновления задач: %w", err)
+	}
+	return nil
+}
+
+// setTaskTags заменяет метки задачи, создавая недостающие
+func setTaskTags(ctx context.Context, tx *sql.Tx, taskID int64, names []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM task_tags WHERE task_id = ?`, taskID); err != nil {
+		return fmt.Errorf("ошибка обновления меток: %w", err)
+	}
+	for _, name := range names {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO tags (name) VALUES (?) ON CONFLICT (name) DO NOTHING`, name); err != nil {
+			return fmt.Errorf("ошибка сохранения метки: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO task_tags (task_id, tag_id)
+			SELECT ?, id FROM tags WHERE name = ?`, taskID, name); err != nil {
+			return fmt.Errorf("ошибка обновления меток: %w", err)
+		}
+	}
+	return nil
+}
+
+// loadTags заполняет Task.Tags у задач одним запросом. Идентификаторы
+// передаются одним параметром — массивом JSON, поэтому число задач
+// не упирается в ограничение SQLite на число параметров.
+func loadTags(ctx context.Context, q querier, tasks ...*Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	byID := make(map[int64]*Task, len(tasks))
+	ids := make([]int64, 0, len(tasks))
+	for _, t := range tasks {
+		t.Tags = make([]string, 0)
+		byID[t.ID] = t
+		ids = append(ids, t.ID)
+	}
+	idList, err := json.Marshal(ids)
+	if err != nil {
+		return err
+	}
+
+	rows, err := q.QueryContext(ctx, `SELECT task_tags.task_id, tags.name
+		FROM task_tags JOIN tags ON tags.id = task_tags.tag_id
+		WHERE task_tags.task_id IN (SELECT value FROM json_each(?)) ORDER BY tags.name`, string(idList))
+	if err != nil {
+		return fmt.Errorf("ошибка запроса меток: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return fmt.Errorf("ошибка чтения данных: %w", err)
+		}
+		byID[id].Tags = append(byID[id].Tags, name)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("ошибка при обработке результатов: %w", err)
+	}
+	return nil
+}
@@ -1,136 +1,421 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"go1f/pkg/dateutil"
+	"strconv"
+	"strings"
 	"time"
 )
 
 const DateFormat = "20060102"
 
 type Task struct {
-	ID      int64  `json:"id"`
-	Date    string `json:"date"`
-	Title   string `json:"title"`
-	Comment string `json:"comment"`
-	Repeat  string `json:"repeat"`
+	ID        int64  `json:"id"`
+	Date      string `json:"date"`
+	Title     string `json:"title"`
+	Comment   string `json:"comment"`
+	Repeat    string `json:"repeat"`
+	Remaining int64  `json:"remaining"` // сколько повторений осталось, 0 — без ограничения
+	Time      string `json:"time"`      // время ЧЧ:ММ, пустое у задач на весь день
+	Version   int64  `json:"version"`   // растёт при каждом изменении задачи
+	// имена меток по алфавиту; при сохранении nil оставляет метки задачи
+	// без изменений, а пустой список снимает их
+	Tags []string `json:"tags"`
+	// фрагмент заголовка или комментария, где найдены слова поиска,
+	// заполняется только в результатах поиска
+	Snippet string `json:"snippet,omitempty"`
 }
 
-func (s *Store) AddTask(task *Task) (int64, error) {
-	res, err := s.db.Exec(
-		`INSERT INTO scheduler (date, title, comment, repeat) VALUES (?, ?, ?, ?)`,
-		task.Date, task.Title, task.Comment, task.Repeat,
+// NextFunc вычисляет по задаче и её пропущенным датам следующие дату и время
+// серии. Пустая дата означает, что задача выполнена окончательно.
+type NextFunc func(task *Task, exceptions []string) (date, time string, err error)
+
+// querier — общее у *sql.DB и *sql.Tx, чтобы читать задачи и внутри транзакции
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+const taskColumns = "id, date, time, title, comment, repeat, remaining, version"
+
+// scanTask читает задачу из строки, выбранной со столбцами taskColumns,
+// и следующие за ними столбцы extra
+func scanTask(row interface{ Scan(...interface{}) error }, extra ...interface{}) (*Task, error) {
+	var t Task
+	dest := []interface{}{&t.ID, &t.Date, &t.Time, &t.Title, &t.Comment, &t.Repeat, &t.Remaining, &t.Version}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Rule разбирает правило повторения задачи
+func (t *Task) Rule() (dateutil.Rule, error) {
+	return dateutil.ParseRule(t.Repeat)
+}
+
+// Start возвращает дату задачи вместе со временем, если оно указано
+func (t *Task) Start() (time.Time, error) {
+	if t.Time == "" {
+		return time.Parse(DateFormat, t.Date)
+	}
+	return time.Parse(dateutil.DateTimeFormat, t.Date+" "+t.Time)
+}
+
+func (s *Store) AddTask(ctx context.Context, task *Task) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO scheduler (date, time, title, comment, repeat, remaining) VALUES (?, ?, ?, ?, ?, ?)`,
+		task.Date, task.Time, task.Title, task.Comment, task.Repeat, task.Remaining,
 	)
 	if err != nil {
 		return 0, err
 	}
-	return res.LastInsertId()
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	task.Tags = tagNames(task.Tags)
+	if err := setTaskTags(ctx, tx, id, task.Tags); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
 }
 
-func (s *Store) Tasks(limit int, search string) ([]*Task, error) {
-	query := "SELECT id, date, title, comment, repeat FROM scheduler"
-	args := []interface{}{}
+// Tasks возвращает страницу задач. Строка поиска в виде ДД.ММ.ГГГГ
+// выбирает задачи на эту дату, иначе задачи ищутся по словам в заголовке
+// и комментарии через полнотекстовый индекс: каждое слово поиска должно
+// быть началом слова задачи, регистр не учитывается. Релевантность
+// считается по bm25, совпадения в заголовке весят больше.
+// Страницы выбираются по ключу сортировки, а не по смещению, поэтому
+// добавление и удаление задач не сдвигает следующие страницы.
+func (s *Store) Tasks(ctx context.Context, q TaskQuery) (*TaskPage, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 
-	parsedDate, err := time.Parse("02.01.2006", search)
+	page := &TaskPage{Tasks: make([]*Task, 0)}
+	from := "scheduler"
+	scoreExpr, snippetExpr := "0", "''"
+	var (
+		where []string
+		args  []interface{}
+	)
+
+	parsedDate, err := time.Parse("02.01.2006", q.Search)
+	search := err != nil && q.Search != ""
 	if err == nil {
-		search = parsedDate.Format(DateFormat)
-		query += " WHERE date = ?"
-		args = append(args, search)
-	} else if search != "" {
-		searchTerm := "%" + search + "%"
-		query += " WHERE (title LIKE ? OR comment LIKE ?)"
-		args = append(args, searchTerm, searchTerm)
+		where = append(where, "scheduler.date = ?")
+		args = append(args, parsedDate.Format(DateFormat))
+	} else if search {
+		words := searchWords(q.Search)
+		if len(words) == 0 {
+			return page, nil
+		}
+		from += ` JOIN (SELECT rowid, bm25(scheduler_fts, 2.0, 1.0) AS score,
+			snippet(scheduler_fts, -1, ?, ?, ?, ?) AS snippet
+			FROM scheduler_fts WHERE scheduler_fts MATCH ?) AS found ON found.rowid = scheduler.id`
+		args = append(args, SnippetStart, SnippetEnd, snippetEllipsis, snippetTokens, matchQuery(words))
+		scoreExpr, snippetExpr = "found.score", "found.snippet"
 	}
+	filters, filterArgs := q.where()
+	where = append(where, filters...)
+	args = append(args, filterArgs...)
 
-	query += " ORDER BY date LIMIT ?"
+	key, err := q.order(search)
+	if err != nil {
+		return nil, err
+	}
+	columns := make([]string, 0, len(key.columns))
+	params := make([]string, 0, len(key.columns))
+	order := make([]string, 0, len(key.columns))
+	for _, column := range key.columns {
+		expr, param := "scheduler."+column, "?"
+		switch column {
+		case "score":
+			expr, param = scoreExpr, "CAST(? AS REAL)"
+		case "id":
+			param = "CAST(? AS INTEGER)"
+		}
+		columns = append(columns, expr)
+		params = append(params, param)
+		if q.Desc {
+			expr += " DESC"
+		}
+		order = append(order, expr)
+	}
+
+	filter := ""
+	if len(where) > 0 {
+		filter = " WHERE " + strings.Join(where, " AND ")
+	}
+	err = s.db.QueryRowContext(ctx, "SELECT count(*) FROM "+from+filter, args...).Scan(&page.Total)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка подсчёта задач: %w", err)
+	}
+
+	// Следующая страница начинается после задачи из курсора
+	if q.Cursor != "" {
+		after, err := decodeCursor(q, key)
+		if err != nil {
+			return nil, err
+		}
+		op := ">"
+		if q.Desc {
+			op = "<"
+		}
+		where = append(where, "("+strings.Join(columns, ", ")+") "+op+" ("+strings.Join(params, ", ")+")")
+		for _, v := range after {
+			args = append(args, v)
+		}
+	}
+	if len(where) > 0 {
+		filter = " WHERE " + strings.Join(where, " AND ")
+	}
+
+	// Лимит -1 в SQLite снимает ограничение
+	limit := -1
+	if q.Limit > 0 {
+		limit = q.Limit + 1
+	}
+	query := "SELECT " + taskColumns + ", " + scoreExpr + ", " + snippetExpr + " FROM " + from + filter +
+		" ORDER BY " + strings.Join(order, ", ") + " LIMIT ?"
 	args = append(args, limit)
 
-	rows, err := s.db.Query(query, args...)
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("ошибка запроса: %v", err)
+		return nil, fmt.Errorf("ошибка запроса: %w", err)
 	}
 	defer rows.Close()
 
-	var tasks []*Task
+	var last float64
 	for rows.Next() {
-		var t Task
-		if err := rows.Scan(&t.ID, &t.Date, &t.Title, &t.Comment, &t.Repeat); err != nil {
-			return nil, fmt.Errorf("ошибка чтения данных: %v", err)
+		var score float64
+		var snippet string
+		t, err := scanTask(rows, &score, &snippet)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения данных: %w", err)
+		}
+		// Лишняя задача только показывает, что есть следующая страница
+		if q.Limit > 0 && len(page.Tasks) == q.Limit {
+			page.NextCursor = cursor{Sort: key.name, Desc: q.Desc, Key: key.values(page.Tasks[q.Limit-1], last)}.encode()
+			break
 		}
-		tasks = append(tasks, &t)
+		t.Snippet = snippet
+		page.Tasks = append(page.Tasks, t)
+		last = score
 	}
 
 	// Проверка на ошибки после итерации
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("ошибка при обработке результатов: %v", err)
+		return nil, fmt.Errorf("ошибка при обработке результатов: %w", err)
 	}
 
-	if tasks == nil {
-		tasks = make([]*Task, 0)
+	if err := loadTags(ctx, s.db, page.Tasks...); err != nil {
+		return nil, err
 	}
+	return page, nil
+}
 
-	return tasks, nil
+// parseID проверяет идентификатор задачи, переданный строкой
+func parseID(id string) (int64, error) {
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidID
+	}
+	return n, nil
 }
 
-func (s *Store) GetTask(id string) (*Task, error) {
-	var task Task
-	query := "SELECT id, date, title, comment, repeat FROM scheduler WHERE id = ?"
-	row := s.db.QueryRow(query, id)
-	err := row.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat)
+func (s *Store) GetTask(ctx context.Context, id string) (*Task, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	n, err := parseID(id)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("задача не найдена")
-		}
 		return nil, err
 	}
-	return &task, nil
+
+	return getTask(ctx, s.db, n)
 }
 
-func (s *Store) UpdateTask(task *Task) error {
-	query := `UPDATE scheduler SET date=?, title=?, comment=?, repeat=? WHERE id=?`
-	res, err := s.db.Exec(query, task.Date, task.Title, task.Comment, task.Repeat, task.ID)
+func getTask(ctx context.Context, q querier, id int64) (*Task, error) {
+	task, err := scanTask(q.QueryRowContext(ctx, "SELECT "+taskColumns+" FROM scheduler WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
 	if err != nil {
-		return fmt.Errorf("ошибка обновления: %v", err)
+		return nil, err
 	}
+	return task, loadTags(ctx, q, task)
+}
 
-	rowsAffected, err := res.RowsAffected()
+// changed объясняет, почему условное обновление не затронуло ни одной строки:
+// задачи нет или она уже изменилась
+func (s *Store) changed(ctx context.Context, id int64) error {
+	var exists bool
+	err := s.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM scheduler WHERE id = ?)`, id).Scan(&exists)
 	if err != nil {
-		return fmt.Errorf("ошибка проверки обновления: %v", err)
+		return fmt.Errorf("ошибка проверки задачи: %w", err)
 	}
-	if rowsAffected == 0 {
-		return fmt.Errorf("задача не найдена")
+	if !exists {
+		return ErrNotFound
 	}
-	return nil
+	return ErrConflict
+}
+
+// UpdateTask сохраняет задачу. Счётчик оставшихся повторений
+// сбрасывается, только если изменилось правило повторения.
+// Если task.Version больше нуля, задача сохраняется только при совпадении
+// версии, иначе возвращается ErrConflict. В task.Version записывается новая версия,
+// в task.Tags — метки задачи.
+func (s *Store) UpdateTask(ctx context.Context, task *Task) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE scheduler SET date=?, time=?, title=?, comment=?,
+		remaining = CASE WHEN repeat = ? THEN remaining ELSE ? END, repeat=?, version = version + 1
+		WHERE id=? AND (? = 0 OR version = ?) RETURNING version`
+	err = tx.QueryRowContext(ctx, query, task.Date, task.Time, task.Title, task.Comment,
+		task.Repeat, task.Remaining, task.Repeat, task.ID, task.Version, task.Version).Scan(&task.Version)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return s.changed(ctx, task.ID)
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка обновления: %w", err)
+	}
+
+	if task.Tags != nil {
+		task.Tags = tagNames(task.Tags)
+		if err := setTaskTags(ctx, tx, task.ID, task.Tags); err != nil {
+			return err
+		}
+	} else if err := loadTags(ctx, tx, task); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (s *Store) DeleteTask(id string) error {
-	query := `DELETE FROM scheduler WHERE id = ?`
-	res, err := s.db.Exec(query, id)
+// DeleteTask удаляет задачу. Если version больше нуля, задача удаляется
+// только при совпадении версии, иначе возвращается ErrConflict.
+func (s *Store) DeleteTask(ctx context.Context, id string, version int64) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	n, err := parseID(id)
 	if err != nil {
-		return fmt.Errorf("ошибка удаления: %v", err)
+		return err
+	}
+
+	query := `DELETE FROM scheduler WHERE id = ? AND (? = 0 OR version = ?)`
+	res, err := s.db.ExecContext(ctx, query, n, version, version)
+	if err != nil {
+		return fmt.Errorf("ошибка удаления: %w", err)
 	}
 	rowsAffected, err := res.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("ошибка проверки удаления: %v", err)
+		return fmt.Errorf("ошибка проверки удаления: %w", err)
 	}
 	if rowsAffected == 0 {
-		return fmt.Errorf("задача не найдена")
+		return s.changed(ctx, n)
 	}
 	return nil
 }
 
-func (s *Store) UpdateDate(next string, id string) error {
-	query := `UPDATE scheduler SET date = ? WHERE id = ?`
-	res, err := s.db.Exec(query, next, id)
+// UpdateDate переносит задачу на следующую дату и время серии
+// и уменьшает счётчик оставшихся повторений. Если дата или время задачи
+// уже не совпадают с task, например задачу отметили выполненной
+// параллельным запросом, возвращается ErrConflict.
+func (s *Store) UpdateDate(ctx context.Context, next string, nextTime string, task *Task) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE scheduler SET date = ?, time = ?,
+		remaining = CASE WHEN remaining > 0 THEN remaining - 1 ELSE 0 END, version = version + 1
+		WHERE id = ? AND date = ? AND time = ?`
+	res, err := s.db.ExecContext(ctx, query, next, nextTime, task.ID, task.Date, task.Time)
 	if err != nil {
-		return fmt.Errorf("ошибка обновления даты: %v", err)
+		return fmt.Errorf("ошибка обновления даты: %w", err)
 	}
 	rowsAffected, err := res.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("ошибка проверки обновления: %v", err)
+		return fmt.Errorf("ошибка проверки обновления: %w", err)
 	}
 	if rowsAffected == 0 {
-		return fmt.Errorf("задача не найдена")
+		return s.changed(ctx, task.ID)
 	}
 	return nil
 }
+
+// CompleteTask отмечает задачу выполненной в одной транзакции: читает задачу
+// и её пропущенные даты, вычисляет следующую дату функцией next и переносит
+// задачу, уменьшая счётчик повторений, или удаляет её, если серия закончилась.
+// Возвращает перенесённую задачу либо nil, если задача удалена. Если version
+// больше нуля и не совпадает с версией задачи, возвращается ErrConflict.
+func (s *Store) CompleteTask(ctx context.Context, id string, version int64, next NextFunc) (*Task, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	n, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	task, err := getTask(ctx, tx, n)
+	if err != nil {
+		return nil, err
+	}
+	if version > 0 && task.Version != version {
+		return nil, ErrConflict
+	}
+	except, err := exceptions(ctx, tx, n)
+	if err != nil {
+		return nil, err
+	}
+
+	date, nextTime, err := next(task, except)
+	if err != nil {
+		return nil, err
+	}
+	if date == "" {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM scheduler WHERE id = ?`, n); err != nil {
+			return nil, fmt.Errorf("ошибка удаления: %w", err)
+		}
+		return nil, tx.Commit()
+	}
+
+	query := `UPDATE scheduler SET date = ?, time = ?,
+		remaining = CASE WHEN remaining > 0 THEN remaining - 1 ELSE 0 END, version = version + 1
+		WHERE id = ? RETURNING ` + taskColumns
+	task, err = scanTask(tx.QueryRowContext(ctx, query, date, nextTime, n))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка обновления даты: %w", err)
+	}
+	if err := loadTags(ctx, tx, task); err != nil {
+		return nil, err
+	}
+	return task, tx.Commit()
+}
@@ -8,7 +8,7 @@ import (
 	"net/http"
 )
 
-func StartServer(store *db.Store, cfg *config.Config) {
+func StartServer(store db.TaskStore, cfg *config.Config) {
 	api := api.NewAPI(store, cfg)
 	api.Init()
 
@@ -0,0 +1,394 @@
+// Package taskql — язык запросов для поиска задач, например
+//
+//	title:отчёт repeat:w due<20261101 -comment:черновик
+//
+// Запрос состоит из условий через пробел, все условия должны выполняться.
+// Условие — слово для поиска в заголовке и комментарии или поле:значение;
+// "-" перед условием отрицает его. Значение с пробелами берётся в кавычки.
+//
+//	title:слова, comment:слова — слова в заголовке или комментарии: как и при
+//	                             поиске, каждое слово — начало слова задачи
+//	repeat:w                   — вид правила повторения: "w", "mw", "freq=weekly"…,
+//	                             без учёта регистра
+//	due:20261101, date:…       — дата задачи; кроме ":" есть <, <=, > и >=,
+//	                             дата записывается как ГГГГММДД или ДД.ММ.ГГГГ
+//	tag:работа                 — у задачи есть метка, "#" перед именем можно не писать
+package taskql
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const dateFormat = "20060102"
+
+// Поля условий
+const (
+	FieldTitle   = "title"
+	FieldComment = "comment"
+	FieldRepeat  = "repeat"
+	FieldDate    = "date"
+	FieldTag     = "tag"
+)
+
+// TaggedSQL — условие SQL «у задачи есть метка» с именем метки в параметре
+const TaggedSQL = `scheduler.id IN (SELECT task_tags.task_id FROM task_tags
+	JOIN tags ON tags.id = task_tags.tag_id WHERE tags.name = ?)`
+
+// repeatKindSQL — вид правила повторения задачи: первое слово правила
+// (у RRULE — до ";") в нижнем регистре
+const repeatKindSQL = `lower(substr(scheduler.repeat, 1,
+	instr(replace(scheduler.repeat, ';', ' ') || ' ', ' ') - 1))`
+
+// repeatKind возвращает вид правила так же, как repeatKindSQL
+func repeatKind(repeat string) string {
+	kind, _, _ := strings.Cut(strings.ReplaceAll(repeat, ";", " "), " ")
+	return strings.ToLower(kind)
+}
+
+// fields — поля запроса и их синонимы
+var fields = map[string]string{
+	"title":   FieldTitle,
+	"comment": FieldComment,
+	"repeat":  FieldRepeat,
+	"date":    FieldDate,
+	"due":     FieldDate,
+	"tag":     FieldTag,
+}
+
+// Term — одно условие запроса
+type Term struct {
+	Field string   // поле Field*; пустое у слов для поиска в заголовке и комментарии
+	Op    string   // ":", "<", "<=", ">" или ">="
+	Value string   // значение: дата ГГГГММДД, вид правила, имя метки или текст
+	Words []string // слова значения в нижнем регистре для текстовых условий
+	Not   bool
+	Pos   int // позиция условия в запросе, с единицы
+}
+
+// Query — разобранный запрос
+type Query struct {
+	Source string
+	Terms  []Term
+}
+
+// SyntaxError — ошибка в запросе с позицией символа, с единицы
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("позиция %d: %s", e.Pos, e.Msg)
+}
+
+// IsQuery проверяет, записана ли строка поиска на языке запросов:
+// хотя бы одно слово в ней — условие на известное поле
+func IsQuery(s string) bool {
+	for _, word := range strings.Fields(s) {
+		name, _, ok := splitField(strings.TrimPrefix(word, "-"))
+		if _, known := fields[name]; ok && known {
+			return true
+		}
+	}
+	return false
+}
+
+// splitField отделяет имя поля от остатка условия, если слово начинается
+// с буквенного имени и знака ":", "<" или ">"
+func splitField(word string) (name, rest string, ok bool) {
+	i := strings.IndexFunc(word, func(r rune) bool { return !unicode.IsLetter(r) })
+	if i <= 0 || !strings.ContainsAny(word[i:i+1], ":<>") {
+		return "", "", false
+	}
+	return strings.ToLower(word[:i]), word[i:], true
+}
+
+// Parse разбирает запрос. Ошибки возвращаются как *SyntaxError.
+func Parse(s string) (*Query, error) {
+	p := parser{src: s}
+	q := &Query{Source: s}
+	for {
+		p.skipSpaces()
+		if p.pos >= len(p.src) {
+			break
+		}
+		term, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		q.Terms = append(q.Terms, term)
+	}
+	if len(q.Terms) == 0 {
+		return nil, &SyntaxError{Pos: 1, Msg: "пустой запрос"}
+	}
+	return q, nil
+}
+
+type parser struct {
+	src string
+	pos int // смещение в байтах
+}
+
+// at возвращает позицию символа в запросе для сообщений об ошибках
+func (p *parser) at(offset int) int {
+	return utf8.RuneCountInString(p.src[:offset]) + 1
+}
+
+func (p *parser) errorf(offset int, format string, args ...interface{}) error {
+	return &SyntaxError{Pos: p.at(offset), Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) skipSpaces() {
+	for p.pos < len(p.src) {
+		r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		if !unicode.IsSpace(r) {
+			return
+		}
+		p.pos += size
+	}
+}
+
+func (p *parser) term() (Term, error) {
+	start := p.pos
+	term := Term{Op: ":", Pos: p.at(start)}
+	if p.src[p.pos] == '-' {
+		term.Not = true
+		p.pos++
+		if p.pos >= len(p.src) || strings.ContainsRune(" \t\n", rune(p.src[p.pos])) {
+			return term, p.errorf(start, "после «-» нет условия")
+		}
+	}
+
+	// Имя поля — буквы до ":", "<" или ">"
+	fieldStart := p.pos
+	if name, rest, ok := splitField(p.src[p.pos:]); ok {
+		field, known := fields[name]
+		if !known {
+			return term, p.errorf(fieldStart, "неизвестное поле «%s»", name)
+		}
+		term.Field = field
+		p.pos += len(p.src[p.pos:]) - len(rest)
+		for _, op := range []string{"<=", ">=", ":", "<", ">"} {
+			if strings.HasPrefix(rest, op) {
+				term.Op = op
+				p.pos += len(op)
+				break
+			}
+		}
+	}
+
+	valueStart := p.pos
+	value, err := p.value()
+	if err != nil {
+		return term, err
+	}
+	if value == "" {
+		if term.Field == "" {
+			return term, p.errorf(valueStart, "пустое условие")
+		}
+		return term, p.errorf(valueStart, "не указано значение поля %s", term.Field)
+	}
+
+	switch term.Field {
+	case FieldDate:
+		date, err := parseDate(value)
+		if err != nil {
+			return term, p.errorf(valueStart, "некорректная дата «%s», нужна ГГГГММДД или ДД.ММ.ГГГГ", value)
+		}
+		term.Value = date
+	case FieldRepeat:
+		if term.Op != ":" {
+			return term, p.errorf(fieldStart, "поле %s не сравнивается через %s", term.Field, term.Op)
+		}
+		term.Value = strings.ToLower(value)
+	case FieldTag:
+		if term.Op != ":" {
+			return term, p.errorf(fieldStart, "поле %s не сравнивается через %s", term.Field, term.Op)
+		}
+		// Имена меток хранятся без "#" и в нижнем регистре
+		term.Value = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(value, "#")))
+		if term.Value == "" {
+			return term, p.errorf(valueStart, "не указано имя метки")
+		}
+	default:
+		if term.Op != ":" {
+			return term, p.errorf(fieldStart, "поле %s не сравнивается через %s", term.Field, term.Op)
+		}
+		term.Value = value
+		term.Words = Words(value)
+		if len(term.Words) == 0 {
+			return term, p.errorf(valueStart, "в «%s» нет букв и цифр для поиска", value)
+		}
+	}
+	return term, nil
+}
+
+// value читает значение условия: слово до пробела или текст в кавычках
+func (p *parser) value() (string, error) {
+	if p.pos < len(p.src) && p.src[p.pos] == '"' {
+		start := p.pos
+		end := strings.IndexByte(p.src[p.pos+1:], '"')
+		if end < 0 {
+			return "", p.errorf(start, "незакрытая кавычка")
+		}
+		p.pos += end + 2
+		return p.src[start+1 : start+1+end], nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.src) {
+		r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		if unicode.IsSpace(r) {
+			break
+		}
+		p.pos += size
+	}
+	return p.src[start:p.pos], nil
+}
+
+// parseDate приводит дату ГГГГММДД или ДД.ММ.ГГГГ к виду ГГГГММДД
+func parseDate(s string) (string, error) {
+	for _, layout := range []string{dateFormat, "02.01.2006"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format(dateFormat), nil
+		}
+	}
+	return "", fmt.Errorf("некорректная дата %q", s)
+}
+
+// Words разбивает текст на слова в нижнем регистре так же,
+// как полнотекстовый индекс задач: слово — буквы и цифры подряд
+func Words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// And объединяет условия двух запросов
+func (q *Query) And(other *Query) *Query {
+	if q == nil {
+		return other
+	}
+	if other == nil {
+		return q
+	}
+	return &Query{
+		Source: q.Source + " " + other.Source,
+		Terms:  append(append([]Term(nil), q.Terms...), other.Terms...),
+	}
+}
+
+// Where компилирует запрос в условия SQL для таблицы scheduler с полнотекстовым
+// индексом scheduler_fts. Значения передаются параметрами запроса.
+func (q *Query) Where() ([]string, []interface{}) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	for _, t := range q.Terms {
+		var cond string
+		switch t.Field {
+		case FieldDate:
+			op := t.Op
+			if op == ":" {
+				op = "="
+			}
+			cond = "scheduler.date " + op + " ?"
+			args = append(args, t.Value)
+		case FieldRepeat:
+			cond = repeatKindSQL + " = ?"
+			args = append(args, t.Value)
+		case FieldTag:
+			cond = TaggedSQL
+			args = append(args, t.Value)
+		default:
+			cond = "scheduler.id IN (SELECT rowid FROM scheduler_fts WHERE scheduler_fts MATCH ?)"
+			args = append(args, t.match())
+		}
+		if t.Not {
+			cond = "NOT (" + cond + ")"
+		}
+		where = append(where, cond)
+	}
+	return where, args
+}
+
+// match возвращает выражение FTS5 для текстового условия: каждое слово —
+// начало слова в поле. Слова состоят из букв и цифр и берутся в кавычки,
+// поэтому операторы FTS5 в них не действуют.
+func (t Term) match() string {
+	terms := make([]string, 0, len(t.Words))
+	for _, w := range t.Words {
+		terms = append(terms, `"`+w+`"*`)
+	}
+	expr := strings.Join(terms, " ")
+	if t.Field != "" {
+		expr = t.Field + " : (" + expr + ")"
+	}
+	return expr
+}
+
+// Fields — значения полей задачи для Match
+type Fields struct {
+	Title, Comment, Repeat, Date string
+	Tags                         []string // имена меток задачи
+}
+
+// Match проверяет задачу так же, как условия из Where
+func (q *Query) Match(f Fields) bool {
+	for _, t := range q.Terms {
+		if t.holds(f) == t.Not {
+			return false
+		}
+	}
+	return true
+}
+
+// holds проверяет условие без учёта отрицания
+func (t Term) holds(f Fields) bool {
+	switch t.Field {
+	case FieldDate:
+		switch t.Op {
+		case "<":
+			return f.Date < t.Value
+		case "<=":
+			return f.Date <= t.Value
+		case ">":
+			return f.Date > t.Value
+		case ">=":
+			return f.Date >= t.Value
+		}
+		return f.Date == t.Value
+	case FieldRepeat:
+		return repeatKind(f.Repeat) == t.Value
+	case FieldTag:
+		return slices.Contains(f.Tags, t.Value)
+	case FieldTitle:
+		return hasWords(Words(f.Title), t.Words)
+	case FieldComment:
+		return hasWords(Words(f.Comment), t.Words)
+	}
+	return hasWords(append(Words(f.Title), Words(f.Comment)...), t.Words)
+}
+
+// hasWords проверяет, что каждое слово из words — начало одного из слов текста
+func hasWords(text, words []string) bool {
+	for _, w := range words {
+		found := false
+		for _, word := range text {
+			if strings.HasPrefix(word, w) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
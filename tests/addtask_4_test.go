@@ -12,9 +12,23 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func requestJSON(apipath string, values map[string]any, method string) ([]byte, error) {
+	_, body, err := request(apipath, values, method)
+	return body, err
+}
+
+// request выполняет запрос и возвращает вместе с телом ответа его статус
+func request(apipath string, values map[string]any, method string) (int, []byte, error) {
+	status, _, body, err := requestHeader(apipath, values, method, nil)
+	return status, body, err
+}
+
+// requestHeader выполняет запрос с дополнительными заголовками header
+// и возвращает статус, заголовки и тело ответа
+func requestHeader(apipath string, values map[string]any, method string, header http.Header) (int, http.Header, []byte, error) {
 	var (
 		data []byte
 		err  error
@@ -23,22 +37,25 @@ func requestJSON(apipath string, values map[string]any, method string) ([]byte,
 	if len(values) > 0 {
 		data, err = json.Marshal(values)
 		if err != nil {
-			return nil, err
+			return 0, nil, nil, err
 		}
 	}
 	var resp *http.Response
 
 	req, err := http.NewRequest(method, getURL(apipath), bytes.NewBuffer(data))
 	if err != nil {
-		return nil, err
+		return 0, nil, nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	for name, values := range header {
+		req.Header[name] = values
+	}
 
 	client := &http.Client{}
 	if len(Token) > 0 {
 		jar, err := cookiejar.New(nil)
 		if err != nil {
-			return nil, err
+			return 0, nil, nil, err
 		}
 		jar.SetCookies(req.URL, []*http.Cookie{
 			{
@@ -51,13 +68,14 @@ func requestJSON(apipath string, values map[string]any, method string) ([]byte,
 
 	resp, err = client.Do(req)
 	if err != nil {
-		return nil, err
+		return 0, nil, nil, err
 	}
 
 	if resp.Body != nil {
 		defer resp.Body.Close()
 	}
-	return io.ReadAll(resp.Body)
+	body, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, resp.Header, body, err
 }
 
 func postJSON(apipath string, values map[string]any, method string) (map[string]any, error) {
@@ -169,3 +187,86 @@ func TestAddTask(t *testing.T) {
 		check()
 	}
 }
+
+func TestErrorCodes(t *testing.T) {
+	tbl := []struct {
+		path   string
+		method string
+		values map[string]any
+		code   string
+		field  string
+		status int
+	}{
+		{"api/task", http.MethodPost, map[string]any{"date": "20240129"}, "empty_title", "title", 400},
+		{"api/task", http.MethodPost, map[string]any{"date": "28.01.2024", "title": "Тест"}, "invalid_date", "date", 400},
+		{"api/task", http.MethodPost, map[string]any{"title": "Тест", "repeat": "w 8"}, "invalid_rule", "repeat", 400},
+		{"api/task", http.MethodPost, map[string]any{"title": "Тест", "time": "25:00"}, "invalid_time", "time", 400},
+		{"api/task", http.MethodPost, map[string]any{"date": "20240101", "title": "Тест",
+			"repeat": "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30"}, "no_date", "repeat", 400},
+		{"api/task", http.MethodPost, map[string]any{"date": "20260101", "title": "Тест",
+			"repeat": "d 1 until 20260301"}, "series_ended", "repeat", 400},
+		{"api/task", http.MethodPut, map[string]any{"id": "abc", "title": "Тест"}, "invalid_id", "id", 400},
+		{"api/task", http.MethodPut, map[string]any{"id": "999999999", "title": "Тест"}, "not_found", "id", 404},
+		{"api/task?id=999999999", http.MethodGet, nil, "not_found", "id", 404},
+		{"api/task?id=999999999", http.MethodDelete, nil, "not_found", "id", 404},
+		{"api/task?id=abc", http.MethodDelete, nil, "invalid_id", "id", 400},
+		{"api/task/done?id=999999999", http.MethodPost, nil, "not_found", "id", 404},
+		{"api/task/skip?id=abc", http.MethodPost, nil, "invalid_id", "id", 400},
+		{"api/task", http.MethodGet, nil, "missing_param", "id", 400},
+		{"api/tasks?limit=0", http.MethodGet, nil, "invalid_param", "limit", 400},
+		{"api/nextdate?now=20261017&date=20260101&repeat=d%203%20until%2020261001", http.MethodGet, nil,
+			"series_ended", "repeat", 400},
+		{"api/task", http.MethodPatch, nil, "method_not_allowed", "", 405},
+	}
+	for _, v := range tbl {
+		status, body, err := request(v.path, v.values, v.method)
+		assert.NoError(t, err)
+		assert.Equal(t, v.status, status, "%s %s %v", v.method, v.path, v.values)
+		var m map[string]any
+		assert.NoError(t, json.Unmarshal(body, &m))
+		assert.NotEmpty(t, m["error"], v.path)
+		assert.Equal(t, v.code, m["code"], "%s %s %v", v.method, v.path, v.values)
+		if v.field == "" {
+			assert.Nil(t, m["field"])
+		} else {
+			assert.Equal(t, v.field, m["field"], "%s %s %v", v.method, v.path, v.values)
+		}
+	}
+
+	// Последнее повторение серии приходится на сегодня — задача добавляется
+	today := time.Now().Format(`20060102`)
+	ret, err := postJSON("api/task", map[string]any{"date": today, "title": "Последний день серии",
+		"repeat": "d 1 until " + today}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Nil(t, ret["error"])
+	assert.Equal(t, today, ret["date"])
+
+	// Серия, закончившаяся до сегодняшнего дня, — ошибка запроса, а не сервера
+	id := addTask(t, task{date: "20260101", title: "Закончившаяся серия"})
+	status, body, err := request("api/task", map[string]any{"id": id, "date": "20260101",
+		"title": "Закончившаяся серия", "repeat": "d 1 until 20260301"}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, status)
+	var resp map[string]any
+	assert.NoError(t, json.Unmarshal(body, &resp))
+	assert.Equal(t, "series_ended", resp["code"])
+
+	m, err := postJSON("api/task?lang=en", map[string]any{"date": "20240129"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, "Task title is required", m["error"])
+
+	// Текст внутренней ошибки не попадает в ответ: испорченный день
+	// календаря ломает расчёт даты по правилу "b"
+	db := openDB(t)
+	defer db.Close()
+	_, err = db.Exec(`INSERT INTO holidays (date, title, workday) VALUES ('ooops', '', 0)`)
+	require.NoError(t, err)
+	defer db.Exec(`DELETE FROM holidays WHERE date = 'ooops'`)
+	status, body, err = request("api/nextdate?now=20240126&date=20240126&repeat=b%201", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, status)
+	resp = nil
+	assert.NoError(t, json.Unmarshal(body, &resp))
+	assert.Equal(t, "internal", resp["code"])
+	assert.NotContains(t, resp, "detail")
+}
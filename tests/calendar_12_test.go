@@ -0,0 +1,217 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"go1f/pkg/dateutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func calendarDate(t *testing.T, s string) time.Time {
+	d, err := time.Parse("20060102", s)
+	require.NoError(t, err)
+	return d
+}
+
+func TestParseCalendar(t *testing.T) {
+	ics := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20310101\r\nDTEND;VALUE=DATE:20310103\r\n" +
+		"SUMMARY:Новогодние\\,\r\n  каникулы\r\nEND:VEVENT\r\nBEGIN:VEVENT\r\nDTSTART:20310308T000000\r\n" +
+		"SUMMARY:8 Марта\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	holidays, err := dateutil.ParseCalendar([]byte(ics))
+	require.NoError(t, err)
+	assert.Equal(t, []dateutil.Holiday{
+		{Date: calendarDate(t, "20310101"), Title: "Новогодние, каникулы"},
+		{Date: calendarDate(t, "20310102"), Title: "Новогодние, каникулы"},
+		{Date: calendarDate(t, "20310308"), Title: "8 Марта"},
+	}, holidays)
+
+	// Заголовок пропускается, разделитель — точка с запятой
+	csv := "\xef\xbb\xbfДата;Название;Тип\n01.01.2031;Новый год;\n2031-01-11;Перенос;рабочий\n"
+	holidays, err = dateutil.ParseCalendar([]byte(csv))
+	require.NoError(t, err)
+	assert.Equal(t, []dateutil.Holiday{
+		{Date: calendarDate(t, "20310101"), Title: "Новый год"},
+		{Date: calendarDate(t, "20310111"), Title: "Перенос", Workday: true},
+	}, holidays)
+
+	// Производственный календарь с data.gov.ru: в январе суббота 11-го не
+	// указана среди нерабочих дней и становится рабочей
+	production := "Год/Месяц,Январь,Февраль,Март,Апрель,Май,Июнь,Июль,Август,Сентябрь,Октябрь,Ноябрь,Декабрь\n" +
+		`2031,"1,2,3+,4,5,6*,12,18,19,25,26",,,,,,,,,,,` + "\n"
+	holidays, err = dateutil.ParseCalendar([]byte(production))
+	require.NoError(t, err)
+	days := make(map[string]dateutil.Holiday)
+	for _, h := range holidays {
+		days[h.Date.Format("20060102")] = h
+	}
+	assert.Equal(t, dateutil.Holiday{Date: calendarDate(t, "20310101"), Title: "Нерабочий день"}, days["20310101"])
+	assert.Equal(t, dateutil.Holiday{Date: calendarDate(t, "20310103"), Title: "Нерабочий день"}, days["20310103"])
+	assert.Equal(t, dateutil.Holiday{Date: calendarDate(t, "20310111"), Title: "Рабочий день", Workday: true}, days["20310111"])
+	assert.NotContains(t, days, "20310104")
+	assert.NotContains(t, days, "20310106")
+	assert.Contains(t, days, "20311227")
+
+	// Размер календаря ограничен и для формата data.gov.ru: каждый год без
+	// нерабочих дней даёт около сотни рабочих суббот и воскресений
+	var huge strings.Builder
+	huge.WriteString("Год/Месяц,Январь,Февраль,Март,Апрель,Май,Июнь,Июль,Август,Сентябрь,Октябрь,Ноябрь,Декабрь\n")
+	for year := 2000; year < 2200; year++ {
+		fmt.Fprintf(&huge, "%d,,,,,,,,,,,,\n", year)
+	}
+
+	for _, data := range []string{
+		huge.String(),
+		"",
+		"Дата,Название\nooops,Праздник\n",
+		"20310101,Новый год,отпуск\n",
+		"20310101,\"Новый год\n",
+		"BEGIN:VCALENDAR\nBEGIN:VEVENT\nSUMMARY:Без даты\nEND:VEVENT\nEND:VCALENDAR\n",
+		"BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:2031\nEND:VEVENT\nEND:VCALENDAR\n",
+		"Год/Месяц,Январь\n2031,1\n",
+		"Год/Месяц,Январь,Февраль,Март,Апрель,Май,Июнь,Июль,Август,Сентябрь,Октябрь,Ноябрь,Декабрь\n" +
+			"2031,,30,,,,,,,,,,\n",
+	} {
+		_, err := dateutil.ParseCalendar([]byte(data))
+		assert.ErrorIs(t, err, dateutil.ErrInvalidCalendar, "%q", data)
+	}
+}
+
+func TestBusinessCalendar(t *testing.T) {
+	cal := dateutil.NewCalendar([]dateutil.Holiday{
+		{Date: calendarDate(t, "20310106"), Title: "Праздник"},
+		{Date: calendarDate(t, "20310107"), Title: "Праздник"},
+		{Date: calendarDate(t, "20310111"), Title: "Перенос", Workday: true},
+	})
+	assert.False(t, cal.IsWorkday(calendarDate(t, "20310106")))
+	assert.True(t, cal.IsWorkday(calendarDate(t, "20310111")))
+	assert.True(t, (*dateutil.Calendar)(nil).IsWorkday(calendarDate(t, "20310106")))
+	assert.False(t, (*dateutil.Calendar)(nil).IsWorkday(calendarDate(t, "20310111")))
+
+	// Без календаря рабочими считаются будни
+	tbl := []struct {
+		rule   string
+		cal    *dateutil.Calendar
+		now    string
+		expect string
+	}{
+		{"b 1", nil, "20310103", "20310106"},
+		{"b 1", cal, "20310103", "20310108"},
+		{"b 1", cal, "20310110", "20310111"},
+		{"b 2 count 3", nil, "20310103", "20310107"},
+		{"b 2 count 3", cal, "20310103", "20310109"},
+		{"m 6 shift next", nil, "20310103", "20310106"},
+		{"m 6 shift next", cal, "20310103", "20310108"},
+		{"m 6 shift prev", cal, "20310102", "20310103"},
+	}
+	for _, v := range tbl {
+		rule, err := dateutil.ParseRule(v.rule)
+		require.NoError(t, err, v.rule)
+		next, err := dateutil.WithCalendar(rule, v.cal).Next(calendarDate(t, v.now), calendarDate(t, "20310101"))
+		require.NoError(t, err, v.rule)
+		assert.Equal(t, v.expect, next.Format("20060102"), "%s после %s", v.rule, v.now)
+	}
+}
+
+// uploadCalendar загружает календарь в /api/holidays телом запроса или,
+// если указано имя файла, полем file в multipart/form-data
+func uploadCalendar(t *testing.T, data, filename string) (int, map[string]any) {
+	body := bytes.NewBufferString(data)
+	contentType := "text/csv"
+	if filename != "" {
+		body = &bytes.Buffer{}
+		form := multipart.NewWriter(body)
+		part, err := form.CreateFormFile("file", filename)
+		require.NoError(t, err)
+		_, err = io.WriteString(part, data)
+		require.NoError(t, err)
+		require.NoError(t, form.Close())
+		contentType = form.FormDataContentType()
+	}
+
+	req, err := http.NewRequest(http.MethodPost, getURL("api/holidays"), body)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", contentType)
+	if len(Token) > 0 {
+		req.AddCookie(&http.Cookie{Name: "token", Value: Token})
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var m map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&m))
+	return resp.StatusCode, m
+}
+
+func TestHolidays(t *testing.T) {
+	nextDate := func(date, repeat string) string {
+		body, err := requestJSON("api/nextdate?now="+date+"&date="+date+"&repeat="+url.QueryEscape(repeat),
+			nil, http.MethodGet)
+		assert.NoError(t, err)
+		return string(body)
+	}
+	// Дни далеко в будущем, чтобы не задеть задачи других тестов
+	days := []string{"20310106", "20310107", "20310108", "20310111"}
+	t.Cleanup(func() {
+		for _, day := range days {
+			request("api/holidays?date="+day, nil, http.MethodDelete)
+		}
+	})
+
+	status, m := uploadCalendar(t, "20310106,Праздник\n20310107,Праздник\n20310111,Перенос,workday\n", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, float64(3), m["loaded"])
+	ics := "BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART;VALUE=DATE:20310108\nSUMMARY:Праздник\nEND:VEVENT\nEND:VCALENDAR\n"
+	status, m = uploadCalendar(t, ics, "calendar.ics")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, float64(1), m["loaded"])
+
+	body, err := requestJSON("api/holidays", nil, http.MethodGet)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `{"date":"20310106","title":"Праздник","workday":false}`)
+	assert.Contains(t, string(body), `{"date":"20310108","title":"Праздник","workday":false}`)
+	assert.Contains(t, string(body), `{"date":"20310111","title":"Перенос","workday":true}`)
+
+	// Правила "b" и "shift" учитывают загруженный календарь
+	assert.Equal(t, "20310109", nextDate("20310103", "b 1"))
+	assert.Equal(t, "20310111", nextDate("20310110", "b 1"))
+	assert.Equal(t, "20310109", nextDate("20310101", "m 6 shift next"))
+
+	tbl := []struct {
+		data     string
+		filename string
+	}{
+		{"ooops\nooops\n", ""},
+		{"20310106,Праздник,отпуск\n", ""},
+		{"BEGIN:VCALENDAR\nBEGIN:VEVENT\nSUMMARY:Без даты\nEND:VEVENT\nEND:VCALENDAR\n", "calendar.ics"},
+		{"", "calendar.csv"},
+	}
+	for _, v := range tbl {
+		status, m := uploadCalendar(t, v.data, v.filename)
+		assert.Equal(t, http.StatusBadRequest, status, v.data)
+		assert.Equal(t, "invalid_calendar", m["code"], v.data)
+		assert.Equal(t, "file", m["field"], v.data)
+	}
+
+	// Ошибочный файл не меняет календарь
+	assert.Equal(t, "20310109", nextDate("20310103", "b 1"))
+
+	for _, day := range days {
+		status, _, err := request("api/holidays?date="+day, nil, http.MethodDelete)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, status)
+	}
+	assert.Equal(t, "20310106", nextDate("20310103", "b 1"))
+	assert.Equal(t, "20310106", nextDate("20310101", "m 6 shift next"))
+}
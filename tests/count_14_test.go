@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"go1f/pkg/dateutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Серии с count и с COUNT правила iCalendar дают одни и те же даты: дата начала
+// считается первым повторением, пропущенные даты не считаются
+func TestCountSyntaxes(t *testing.T) {
+	tbl := []struct {
+		native string
+		rrule  string
+		start  string
+		except []string
+		want   []string
+	}{
+		{"d 1 count 3", "FREQ=DAILY;COUNT=3", "20240101", nil, []string{"20240101", "20240102", "20240103"}},
+		{"d 1 count 3", "FREQ=DAILY;COUNT=3", "20240101", []string{"20240102"},
+			[]string{"20240101", "20240103", "20240104"}},
+		{"d 1 count 3", "FREQ=DAILY;COUNT=3", "20240101", []string{"20240101"},
+			[]string{"20240102", "20240103", "20240104"}},
+		{"w 1 count 3", "FREQ=WEEKLY;BYDAY=MO;COUNT=3", "20240103", nil, []string{"20240103", "20240108", "20240115"}},
+		{"m 15 count 2", "FREQ=MONTHLY;BYMONTHDAY=15;COUNT=2", "20240115", []string{"20240215"},
+			[]string{"20240115", "20240315"}},
+	}
+	for _, v := range tbl {
+		start, err := time.Parse("20060102", v.start)
+		require.NoError(t, err)
+		except, err := dateutil.ParseDates(v.except)
+		require.NoError(t, err)
+
+		for _, repeat := range []string{v.native, v.rrule} {
+			rule, err := dateutil.ParseRule(repeat)
+			require.NoError(t, err, repeat)
+			dates := make([]string, 0)
+			for d := range dateutil.Occurrences(dateutil.WithExcept(rule, except), start, start, time.Time{}, 0) {
+				dates = append(dates, d.Format("20060102"))
+			}
+			assert.Equal(t, v.want, dates, "%s %v", repeat, v.except)
+
+			// Следующая дата после каждого повторения — следующее повторение,
+			// после последнего серия закончена
+			for i, date := range v.want {
+				now, err := time.Parse("20060102", date)
+				require.NoError(t, err)
+				next, err := dateutil.NextDate(now, v.start, repeat, nil, v.except...)
+				if i == len(v.want)-1 {
+					assert.ErrorIs(t, err, dateutil.ErrSeriesEnded, "%s после %s", repeat, date)
+					continue
+				}
+				assert.NoError(t, err, "%s после %s", repeat, date)
+				assert.Equal(t, v.want[i+1], next, "%s после %s", repeat, date)
+			}
+		}
+	}
+}
+
+func TestCountAPI(t *testing.T) {
+	for _, repeat := range []string{"d 1 count 3", "FREQ=DAILY;COUNT=3"} {
+		query := "&repeat=" + url.QueryEscape(repeat)
+
+		// Серия из трёх дней, начавшаяся два года назад, давно закончилась
+		body, err := requestJSON("api/nextdate?now=20241018&date=20241017"+query, nil, http.MethodGet)
+		assert.NoError(t, err)
+		assert.Equal(t, "20241019", string(body), repeat)
+		status, body, err := request("api/nextdate?now=20261017&date=20241017"+query, nil, http.MethodGet)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, status, repeat)
+		assert.Contains(t, string(body), `"code":"series_ended"`, repeat)
+
+		ret, err := postJSON("api/task", map[string]any{"date": "20241017", "title": "Три дня", "repeat": repeat},
+			http.MethodPost)
+		assert.NoError(t, err)
+		assert.Equal(t, "series_ended", ret["code"], repeat)
+
+		// Вчерашнее и сегодняшнее повторения прошли и тоже расходуют count:
+		// остаётся одно, завтрашнее
+		now := time.Now()
+		ret, err = postJSON("api/task", map[string]any{"date": now.AddDate(0, 0, -1).Format(`20060102`),
+			"title": "Три дня", "repeat": repeat}, http.MethodPost)
+		assert.NoError(t, err)
+		require.NotNil(t, ret["id"], repeat)
+		assert.Equal(t, now.AddDate(0, 0, 1).Format(`20060102`), ret["date"], repeat)
+		id := fmt.Sprint(ret["id"])
+
+		task, err := postJSON("api/task?id="+id, nil, http.MethodGet)
+		assert.NoError(t, err)
+		assert.Equal(t, "1", task["remaining"], repeat)
+
+		ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret, repeat)
+		notFoundTask(t, id)
+	}
+}
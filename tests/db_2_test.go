@@ -11,11 +11,14 @@ import (
 )
 
 type Task struct {
-	ID      int64  `db:"id"`
-	Date    string `db:"date"`
-	Title   string `db:"title"`
-	Comment string `db:"comment"`
-	Repeat  string `db:"repeat"`
+	ID        int64  `db:"id"`
+	Date      string `db:"date"`
+	Title     string `db:"title"`
+	Comment   string `db:"comment"`
+	Repeat    string `db:"repeat"`
+	Remaining int64  `db:"remaining"`
+	Time      string `db:"time"`
+	Version   int64  `db:"version"`
 }
 
 func count(db *sqlx.DB) (int, error) {
@@ -64,3 +67,14 @@ func TestDB(t *testing.T) {
 
 	assert.Equal(t, before, after)
 }
+
+func TestSchemaVersion(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	// Сервер при запуске применяет все миграции, версия схемы
+	// не меньше последней известной тестам
+	var version int
+	assert.NoError(t, db.Get(&version, `PRAGMA user_version`))
+	assert.GreaterOrEqual(t, version, 5)
+}
@@ -2,6 +2,8 @@ package tests
 
 import (
 	"fmt"
+	"io"
+	"net/http"
 	"net/url"
 	"strings"
 	"testing"
@@ -36,6 +38,8 @@ func TestNextDate(t *testing.T) {
 		{"20240320", "d 401", ""},
 		{"20231225", "d 12", `20240130`},
 		{"20240228", "d 1", "20240229"},
+		{"00010101", "d 1", "20240127"},
+		{"00010101", "y", "20250101"},
 	}
 	check := func() {
 		for _, v := range tbl {
@@ -74,6 +78,106 @@ func TestNextDate(t *testing.T) {
 		{"20240126", "w 7", "20240128"},
 		{"20230126", "w 4,5", "20240201"},
 		{"20230226", "w 8,4,5", ""},
+		{"20240101", "m 31 2", ""},
+		{"20240101", "m 30,31 2,4", "20240430"},
+		{"20240101", "m 29 2", "20240229"},
+		{"20240301", "m 29 2", "20280229"},
+		{"20240101", "mw 2-2", "20240213"},
+		{"20240101", "mw -1-5", "20240223"},
+		{"20240101", "mw 5-4", "20240229"},
+		{"20240101", "mw 1-1,3-3 3,6", "20240304"},
+		{"20240101", "mw -2-7", "20240218"},
+		{"20240101", "mw 6-1", ""},
+		{"20240101", "mw 2-8", ""},
+		{"20240101", "mw 2", ""},
+		{"20240101", "mw 2-2 13", ""},
+		{"20240108", "w 1 /2", "20240205"},
+		{"20240103", "w 1,3 /2", "20240129"},
+		{"20240101", "w 1 /3", "20240212"},
+		{"20231115", "m 10 /3", "20240210"},
+		{"20231115", "m 10 1,4,7,10 /2", "20240710"},
+		{"20240101", "mw 2-2 /2", "20240312"},
+		{"20240115", "m 29 2 /12", ""},
+		{"20240101", "w 1 /0", ""},
+		{"20240101", "m 10 /101", ""},
+		{"20240120", "w 1,5 until 20240131", "20240129"},
+		{"20240120", "w 1,5 until 20240128", ""},
+		{"20240120", "d 10 count 5", "20240130"},
+		{"20240120", "d 10 count 0", ""},
+		{"20240120", "d 10 until 2024", ""},
+		{"20240126", "b 1", "20240129"},
+		{"20240120", "b 5", "20240202"},
+		{"20240120", "b 0", ""},
+		{"20240120", "b", ""},
+		{"20240101", "m 10 shift next", "20240212"},
+		{"20240101", "m 10 shift prev", "20240209"},
+		{"20240101", "m 3 shift prev", "20240202"},
+		{"20240101", "m 10 shift up", ""},
+		{"20240101", "m 10 shift", ""},
+		{"20240120", "d 10 from done", "20240130"},
+		{"20240120", "d 10 from now", ""},
+		{"20240125 09:00", "h 4", "20240126 01:00"},
+		{"20240126 10:00", "min 45", "20240126 10:45"},
+		{"20240120 09:00", "d 3", "20240126 09:00"},
+		{"20240120", "h 0", ""},
+		{"20240120", "min 1441", ""},
+		{"20240120 09:00", "h 4 shift next", ""},
+		{"20240101", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE", "20240129"},
+		{"20240101", "RRULE:FREQ=MONTHLY;BYDAY=-1FR", "20240223"},
+		{"20240101", "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1", "20240131"},
+		{"20240101", "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=29", "20240229"},
+		{"20240101", "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30", ""},
+		{"20240101", "FREQ=DAILY;COUNT=10", ""},
+		{"20240101", "FREQ=DAILY;UNTIL=20240127", "20240127"},
+		{"20240101", "FREQ=HOURLY", ""},
 	}
 	check()
 }
+
+func TestNextDateTime(t *testing.T) {
+	tbl := []struct {
+		now    string
+		date   string
+		repeat string
+		want   string
+	}{
+		// Сегодняшнее время задачи ещё не наступило
+		{"20240126 08:00", "20240125 10:00", "d 1", "20240126 10:00"},
+		{"20240126 10:00", "20240125 10:00", "d 1", "20240127 10:00"},
+		{"20240126 11:00", "20240125 10:00", "d 1", "20240127 10:00"},
+		{"20240126 08:00", "20240126 10:00", "d 1", "20240127 10:00"},
+		{"20240126 09:00", "20240119 18:00", "w 5", "20240126 18:00"},
+		{"20240126 19:00", "20240119 18:00", "w 5", "20240202 18:00"},
+		{"20240126 08:00", "20240125 10:00", "d 1 until 20240126", "20240126 10:00"},
+		{"20240126", "20240125 10:00", "d 1", "20240126 10:00"},
+	}
+	for _, v := range tbl {
+		body, err := getBody(fmt.Sprintf("api/nextdate?now=%s&date=%s&repeat=%s",
+			url.QueryEscape(v.now), url.QueryEscape(v.date), url.QueryEscape(v.repeat)))
+		assert.NoError(t, err)
+		assert.Equal(t, v.want, string(body), "%+v", v)
+	}
+}
+
+func TestNextDateTimezone(t *testing.T) {
+	// Часовые пояса по разные стороны от линии перемены дат: хотя бы в одном
+	// из них сегодняшняя дата отличается от даты в UTC
+	for _, tz := range []string{"Pacific/Kiritimati", "Etc/GMT+12"} {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			t.Skip("нет базы часовых поясов")
+		}
+		today := time.Now().In(loc)
+
+		req, err := http.NewRequest(http.MethodGet, getURL("api/nextdate?date="+
+			today.Format("20060102")+"&repeat="+url.QueryEscape("d 1")), nil)
+		assert.NoError(t, err)
+		req.Header.Set("X-Timezone", tz)
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.NoError(t, err)
+		assert.Equal(t, today.AddDate(0, 0, 1).Format("20060102"), string(body), tz)
+	}
+}
@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"go1f/pkg/dateutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOccurrences(t *testing.T) {
+	date := func(s string) time.Time {
+		if s == "" {
+			return time.Time{}
+		}
+		d, err := time.Parse("20060102", s)
+		require.NoError(t, err)
+		return d
+	}
+	tbl := []struct {
+		start  string
+		repeat string
+		from   string
+		to     string
+		limit  int
+		want   []string
+	}{
+		{"20240101", "d 3", "20240101", "20240110", 0, []string{"20240101", "20240104", "20240107", "20240110"}},
+		{"20240101", "d 3", "20240101", "20240110", 2, []string{"20240101", "20240104"}},
+		{"20240101", "d 3", "20240105", "20240110", 0, []string{"20240107", "20240110"}},
+		{"20240101", "d 3", "20231201", "20240105", 0, []string{"20240101", "20240104"}},
+		{"20240110", "d 3", "20240101", "20240105", 0, []string{}},
+		{"20240101", "w 1", "20240101", "20240121", 0, []string{"20240101", "20240108", "20240115"}},
+		{"20240131", "m -1", "20240101", "", 3, []string{"20240131", "20240229", "20240331"}},
+		// Окончание серии: until включительно, count отсчитывается от даты задачи
+		{"20240101", "d 2 until 20240107", "20240101", "", 0, []string{"20240101", "20240103", "20240105", "20240107"}},
+		{"20240101", "d 1 count 3", "20240101", "", 0, []string{"20240101", "20240102", "20240103"}},
+		{"20240101", "d 1 count 3", "20240102", "", 0, []string{"20240102", "20240103"}},
+		{"20240101", "d 1 count 3", "20240105", "", 0, []string{}},
+		{"20240101", "FREQ=DAILY;INTERVAL=5;COUNT=2", "20240101", "", 10, []string{"20240101", "20240106"}},
+	}
+	for _, v := range tbl {
+		rule, err := dateutil.ParseRule(v.repeat)
+		require.NoError(t, err, v.repeat)
+		dates := make([]string, 0)
+		for d := range dateutil.Occurrences(rule, date(v.start), date(v.from), date(v.to), v.limit) {
+			dates = append(dates, d.Format("20060102"))
+		}
+		assert.Equal(t, v.want, dates, "%+v", v)
+	}
+}
+
+func TestOccurrencesAPI(t *testing.T) {
+	occurrences := func(params string) (int, map[string]any) {
+		status, body, err := request("api/occurrences?"+params, nil, http.MethodGet)
+		assert.NoError(t, err)
+		var m map[string]any
+		assert.NoError(t, json.Unmarshal(body, &m), string(body))
+		return status, m
+	}
+
+	status, m := occurrences("date=20240101&repeat=" + url.QueryEscape("d 3") + "&from=20240101&to=20240110&limit=3")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []any{"20240101", "20240104", "20240107"}, m["dates"])
+
+	// Задача без правила — одна дата, если она попадает в интервал
+	_, m = occurrences("date=20240105&from=20240101&to=20240110")
+	assert.Equal(t, []any{"20240105"}, m["dates"])
+	_, m = occurrences("date=20240115&from=20240101&to=20240110")
+	assert.Equal(t, []any{}, m["dates"])
+
+	// Время задачи сохраняется в датах
+	_, m = occurrences("date=" + url.QueryEscape("20240101 09:30") + "&repeat=" + url.QueryEscape("d 1") +
+		"&from=20240101&limit=2")
+	assert.Equal(t, []any{"20240101 09:30", "20240102 09:30"}, m["dates"])
+
+	// Даты задачи по её идентификатору, с учётом окончания серии
+	id := addTask(t, task{date: time.Now().Format(`20060102`), title: "Вхождения", repeat: "d 1 count 2"})
+	_, m = occurrences("id=" + id + "&limit=10")
+	assert.Len(t, m["dates"], 2)
+
+	tbl := []struct {
+		params string
+		code   string
+		field  string
+		status int
+	}{
+		{"repeat=d%201", "missing_param", "date", 400},
+		{"date=ooops&repeat=d%201", "invalid_param", "date", 400},
+		{"date=20240101&repeat=ooops", "invalid_rule", "repeat", 400},
+		{"date=20240101&repeat=d%201&from=ooops", "invalid_param", "from", 400},
+		{"date=20240101&repeat=d%201&from=20240110&to=20240101", "invalid_param", "to", 400},
+		{"date=20240101&repeat=d%201&limit=0", "invalid_param", "limit", 400},
+		{"date=20240101&repeat=d%201&limit=1001", "invalid_param", "limit", 400},
+		{"id=999999999", "not_found", "id", 404},
+	}
+	for _, v := range tbl {
+		status, m := occurrences(v.params)
+		assert.Equal(t, v.status, status, v.params)
+		assert.Equal(t, v.code, m["code"], v.params)
+		assert.Equal(t, v.field, m["field"], v.params)
+	}
+
+	status, _, err := request("api/occurrences?date=20240101", nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusMethodNotAllowed, status)
+}
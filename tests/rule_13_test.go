@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"go1f/pkg/dateutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRule(t *testing.T) {
+	until := time.Date(2026, time.December, 31, 0, 0, 0, 0, time.UTC)
+	tbl := []struct {
+		rule   string
+		want   dateutil.Rule
+		string string
+	}{
+		{"d 7", dateutil.DailyRule{Days: 7}, "d 7"},
+		{"y", dateutil.YearlyRule{}, "y"},
+		{"w 5,1,1", dateutil.WeeklyRule{Days: []int{1, 5}}, "w 1,5"},
+		{"w 1 /2", dateutil.WeeklyRule{Days: []int{1}, Interval: 2}, "w 1 /2"},
+		{"w 3 /1", dateutil.WeeklyRule{Days: []int{3}}, "w 3"},
+		{"m 31,-1 12,3", dateutil.MonthlyRule{Days: []int{-1, 31}, Months: []int{3, 12}}, "m -1,31 3,12"},
+		{"m 10 /3", dateutil.MonthlyRule{Days: []int{10}, Interval: 3}, "m 10 /3"},
+		{"mw -1-5,2-2 3", dateutil.MonthWeekdayRule{Days: []dateutil.NthWeekday{{N: -1, Day: 5}, {N: 2, Day: 2}},
+			Months: []int{3}}, "mw -1-5,2-2 3"},
+		{"mw 1-1 /6", dateutil.MonthWeekdayRule{Days: []dateutil.NthWeekday{{N: 1, Day: 1}}, Interval: 6}, "mw 1-1 /6"},
+		{"b 3", dateutil.BusinessRule{Days: 3}, "b 3"},
+		{"h 4", dateutil.HourlyRule{Hours: 4}, "h 4"},
+		{"min 30", dateutil.MinuteRule{Minutes: 30}, "min 30"},
+		{"m 1 shift next", dateutil.Shifted{Rule: dateutil.MonthlyRule{Days: []int{1}}, Shift: dateutil.ShiftNext},
+			"m 1 shift next"},
+		{"d 3 from done", dateutil.FromDone{Rule: dateutil.DailyRule{Days: 3}}, "d 3 from done"},
+		{"d 1 count 2 until 20261231", dateutil.Bounded{Rule: dateutil.DailyRule{Days: 1},
+			End: dateutil.End{Until: until, Count: 2}}, "d 1 until 20261231 count 2"},
+		{"m -1 count 3 from done shift prev", dateutil.Bounded{Rule: dateutil.FromDone{Rule: dateutil.Shifted{
+			Rule: dateutil.MonthlyRule{Days: []int{-1}}, Shift: dateutil.ShiftPrev}}, End: dateutil.End{Count: 3}},
+			"m -1 shift prev from done count 3"},
+		{"RRULE:freq=weekly;interval=2;byday=MO,FR", &dateutil.RRule{Freq: "WEEKLY", Interval: 2, Wkst: time.Monday,
+			ByDay: []dateutil.WeekdayNum{{Day: time.Monday}, {Day: time.Friday}}}, "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR"},
+		{"FREQ=DAILY;INTERVAL=100", &dateutil.RRule{Freq: "DAILY", Interval: 100, Wkst: time.Monday},
+			"FREQ=DAILY;INTERVAL=100"},
+		{"FREQ=MONTHLY;BYDAY=-1FR;COUNT=4", &dateutil.RRule{Freq: "MONTHLY", Interval: 1, Wkst: time.Monday, Count: 4,
+			ByDay: []dateutil.WeekdayNum{{N: -1, Day: time.Friday}}}, "FREQ=MONTHLY;BYDAY=-1FR;COUNT=4"},
+	}
+	for _, v := range tbl {
+		rule, err := dateutil.ParseRule(v.rule)
+		require.NoError(t, err, v.rule)
+		assert.Equal(t, v.want, rule, v.rule)
+		assert.Equal(t, v.string, rule.String(), v.rule)
+
+		// Каноническая запись разбирается в то же самое правило
+		again, err := dateutil.ParseRule(rule.String())
+		require.NoError(t, err, rule.String())
+		assert.Equal(t, rule, again, rule.String())
+	}
+}
+
+func TestParseRuleErrors(t *testing.T) {
+	tbl := []struct {
+		rule  string
+		field string
+	}{
+		{"", ""},
+		{"   ", ""},
+		{"x 1", "type"},
+		{"d", "d"},
+		{"d 0", "days"},
+		{"d 401", "days"},
+		{"d 1 2", "d"},
+		{"y 1", "y"},
+		{"w", "w"},
+		{"w 8", "days"},
+		{"w 1,0", "days"},
+		{"w 1 /0", "interval"},
+		{"w 1 /101", "interval"},
+		{"m", "m"},
+		{"m 0", "days"},
+		{"m 32", "days"},
+		{"m -3", "days"},
+		{"m 1 13", "months"},
+		{"m 31 2", "days"},
+		{"mw 2", "days"},
+		{"mw 6-1", "days"},
+		{"mw 1-8", "days"},
+		{"mw 1-1 0", "months"},
+		{"b", "b"},
+		{"b 0", "days"},
+		{"h 0", "hours"},
+		{"min 0", "minutes"},
+		{"h 1 shift next", "shift"},
+		{"d 1 until 2026", "until"},
+		{"d 1 until 20260101 until 20260201", "until"},
+		{"d 1 count 0", "count"},
+		{"d 1 count", "count"},
+		{"d 1 count 1 count 2", "count"},
+		{"d 1 count 10001", "count"},
+		{"d 1 count 2 every", "every"},
+		{"d 1 shift up", "shift"},
+		{"d 1 from now", "from"},
+		{"FREQ=HOURLY", "FREQ"},
+		{"RRULE:INTERVAL=2", "FREQ"},
+		{"FREQ=DAILY;FREQ=DAILY", "FREQ"},
+		{"FREQ=DAILY;INTERVAL=0", "INTERVAL"},
+		{"FREQ=DAILY;INTERVAL=101", "INTERVAL"},
+		{"FREQ=YEARLY;INTERVAL=100000", "INTERVAL"},
+		{"FREQ=DAILY;COUNT=0", "COUNT"},
+		{"FREQ=DAILY;COUNT=10001", "COUNT"},
+		{"FREQ=DAILY;COUNT=2;UNTIL=20260101", "COUNT"},
+		{"FREQ=DAILY;BYDAY=1MO", "BYDAY"},
+		{"FREQ=DAILY;BYSETPOS=1", "BYSETPOS"},
+		{"FREQ=DAILY;FOO=1", "FOO"},
+		{"FREQ=DAILY;INTERVAL", "RRULE"},
+	}
+	for _, v := range tbl {
+		_, err := dateutil.ParseRule(v.rule)
+		assert.ErrorIs(t, err, dateutil.ErrInvalidRule, v.rule)
+		var ruleErr *dateutil.RuleError
+		if assert.ErrorAs(t, err, &ruleErr, v.rule) {
+			assert.Equal(t, v.rule, ruleErr.Rule)
+			assert.Equal(t, v.field, ruleErr.Field, "%q: %v", v.rule, err)
+			assert.NotEmpty(t, ruleErr.Error(), v.rule)
+		}
+	}
+}
@@ -0,0 +1,583 @@
+package tests
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"go1f/pkg/db"
+	"go1f/pkg/taskql"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Обе реализации хранилища проходят один и тот же набор проверок
+func TestStoreConformance(t *testing.T) {
+	stores := map[string]func(t *testing.T) db.TaskStore{
+		"sqlite": func(t *testing.T) db.TaskStore {
+			store, err := db.NewStore(filepath.Join(t.TempDir(), "scheduler.db"))
+			require.NoError(t, err)
+			t.Cleanup(func() { store.Close() })
+			return store
+		},
+		"memory": func(t *testing.T) db.TaskStore {
+			return db.NewMemoryStore()
+		},
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			t.Run("tasks", func(t *testing.T) { testStoreTasks(t, newStore(t)) })
+			t.Run("search", func(t *testing.T) { testStoreSearch(t, newStore(t)) })
+			t.Run("pages", func(t *testing.T) { testStorePages(t, newStore(t)) })
+			t.Run("filters", func(t *testing.T) { testStoreFilters(t, newStore(t)) })
+			t.Run("query", func(t *testing.T) { testStoreQuery(t, newStore(t)) })
+			t.Run("saved", func(t *testing.T) { testStoreSaved(t, newStore(t)) })
+			t.Run("tags", func(t *testing.T) { testStoreTags(t, newStore(t)) })
+			t.Run("dates", func(t *testing.T) { testStoreDates(t, newStore(t)) })
+			t.Run("holidays", func(t *testing.T) { testStoreHolidays(t, newStore(t)) })
+			t.Run("concurrent", func(t *testing.T) { testStoreConcurrent(t, newStore(t)) })
+			t.Run("versions", func(t *testing.T) { testStoreVersions(t, newStore(t)) })
+			t.Run("complete", func(t *testing.T) { testStoreComplete(t, newStore(t)) })
+			t.Run("canceled", func(t *testing.T) { testStoreCanceled(t, newStore(t)) })
+		})
+	}
+}
+
+var ctx = context.Background()
+
+func storeAdd(t *testing.T, store db.TaskStore, task db.Task) string {
+	id, err := store.AddTask(ctx, &task)
+	require.NoError(t, err)
+	return strconv.FormatInt(id, 10)
+}
+
+func testStoreTasks(t *testing.T, store db.TaskStore) {
+	id := storeAdd(t, store, db.Task{Date: "20240126", Title: "Отчёт", Comment: "к пятнице",
+		Repeat: "d 2 count 3", Remaining: 3, Time: "09:30"})
+
+	task, err := store.GetTask(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, db.Task{ID: task.ID, Date: "20240126", Title: "Отчёт", Comment: "к пятнице",
+		Repeat: "d 2 count 3", Remaining: 3, Time: "09:30", Version: 1, Tags: []string{}}, *task)
+
+	_, err = store.GetTask(ctx, "999999")
+	assert.ErrorIs(t, err, db.ErrNotFound)
+	_, err = store.GetTask(ctx, "abc")
+	assert.ErrorIs(t, err, db.ErrInvalidID)
+
+	// Счётчик повторений сохраняется, пока не изменилось правило
+	task.Title = "Отчёт за неделю"
+	task.Remaining = 10
+	require.NoError(t, store.UpdateTask(ctx, task))
+	task, err = store.GetTask(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "Отчёт за неделю", task.Title)
+	assert.Equal(t, int64(3), task.Remaining)
+
+	task.Repeat = "d 1 count 5"
+	task.Remaining = 5
+	require.NoError(t, store.UpdateTask(ctx, task))
+	task, err = store.GetTask(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), task.Remaining)
+
+	assert.ErrorIs(t, store.UpdateTask(ctx, &db.Task{ID: 999999, Title: "Нет"}), db.ErrNotFound)
+
+	require.NoError(t, store.DeleteTask(ctx, id, 0))
+	_, err = store.GetTask(ctx, id)
+	assert.ErrorIs(t, err, db.ErrNotFound)
+	assert.ErrorIs(t, store.DeleteTask(ctx, id, 0), db.ErrNotFound)
+	assert.ErrorIs(t, store.DeleteTask(ctx, "abc", 0), db.ErrInvalidID)
+}
+
+func testStoreSearch(t *testing.T, store db.TaskStore) {
+	storeAdd(t, store, db.Task{Date: "20240127", Title: "Позвонить маме"})
+	storeAdd(t, store, db.Task{Date: "20240126", Time: "18:00", Title: "Ужин", Comment: "Купить Wine"})
+	storeAdd(t, store, db.Task{Date: "20240126", Title: "Зарядка"})
+	storeAdd(t, store, db.Task{Date: "20240126", Time: "08:00", Title: "Завтрак"})
+
+	titles := func(search string, limit int) []string {
+		page, err := store.Tasks(ctx, db.TaskQuery{Search: search, Limit: limit})
+		require.NoError(t, err)
+		list := make([]string, 0, len(page.Tasks))
+		for _, task := range page.Tasks {
+			list = append(list, task.Title)
+		}
+		return list
+	}
+
+	// Задачи без времени идут раньше задач того же дня со временем
+	assert.Equal(t, []string{"Зарядка", "Завтрак", "Ужин", "Позвонить маме"}, titles("", 10))
+	assert.Equal(t, []string{"Зарядка", "Завтрак"}, titles("", 2))
+	assert.Equal(t, []string{"Позвонить маме"}, titles("27.01.2024", 10))
+	assert.Equal(t, []string{"Ужин"}, titles("wine", 10))
+	assert.Equal(t, []string{"Позвонить маме"}, titles("маме", 10))
+	assert.Equal(t, []string{}, titles("нет такого", 10))
+
+	// Кириллица без учёта регистра, слова поиска — начала слов задачи
+	assert.Equal(t, []string{"Ужин"}, titles("купить", 10))
+	assert.Equal(t, []string{"Позвонить маме"}, titles("ПОЗВОН", 10))
+	assert.Equal(t, []string{"Позвонить маме"}, titles("маме позвонить", 10))
+	assert.Equal(t, []string{}, titles("вонить", 10))
+	assert.Equal(t, []string{}, titles(`"*!`, 10))
+
+	// Совпадение в заголовке важнее, чем в комментарии
+	storeAdd(t, store, db.Task{Date: "20240125", Title: "Магазин", Comment: "Хлеб, молоко"})
+	storeAdd(t, store, db.Task{Date: "20240128", Title: "Молоко"})
+	assert.Equal(t, []string{"Молоко", "Магазин"}, titles("молоко", 10))
+
+	page, err := store.Tasks(ctx, db.TaskQuery{Search: "хлеб", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, page.Tasks, 1)
+	assert.Equal(t, db.SnippetStart+"Хлеб"+db.SnippetEnd+", молоко", page.Tasks[0].Snippet)
+	page, err = store.Tasks(ctx, db.TaskQuery{Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, page.Tasks[0].Snippet)
+}
+
+func testStorePages(t *testing.T, store db.TaskStore) {
+	storeAdd(t, store, db.Task{Date: "20240127", Title: "Бег"})
+	storeAdd(t, store, db.Task{Date: "20240126", Title: "Велосипед", Comment: "спорт"})
+	storeAdd(t, store, db.Task{Date: "20240126", Time: "07:00", Title: "Атлетика", Comment: "спорт"})
+	storeAdd(t, store, db.Task{Date: "20240128", Title: "Гребля", Comment: "спорт"})
+	storeAdd(t, store, db.Task{Date: "20240125", Title: "Дартс"})
+
+	// Все страницы выборки по очереди
+	pages := func(q db.TaskQuery) [][]string {
+		var list [][]string
+		for {
+			page, err := store.Tasks(ctx, q)
+			require.NoError(t, err)
+			titles := make([]string, 0, len(page.Tasks))
+			for _, task := range page.Tasks {
+				titles = append(titles, task.Title)
+			}
+			list = append(list, titles)
+			if page.NextCursor == "" {
+				return list
+			}
+			q.Cursor = page.NextCursor
+		}
+	}
+
+	assert.Equal(t, [][]string{{"Дартс", "Велосипед"}, {"Атлетика", "Бег"}, {"Гребля"}},
+		pages(db.TaskQuery{Limit: 2}))
+	assert.Equal(t, [][]string{{"Гребля", "Бег", "Атлетика"}, {"Велосипед", "Дартс"}},
+		pages(db.TaskQuery{Limit: 3, Desc: true}))
+	assert.Equal(t, [][]string{{"Атлетика", "Бег"}, {"Велосипед", "Гребля"}, {"Дартс"}},
+		pages(db.TaskQuery{Limit: 2, Sort: db.SortTitle}))
+	assert.Equal(t, [][]string{{"Дартс", "Гребля", "Атлетика", "Велосипед", "Бег"}},
+		pages(db.TaskQuery{Limit: 5, Sort: db.SortCreated, Desc: true}))
+	assert.Equal(t, [][]string{{"Велосипед", "Атлетика"}, {"Гребля"}},
+		pages(db.TaskQuery{Search: "спорт", Limit: 2}))
+
+	page, err := store.Tasks(ctx, db.TaskQuery{Search: "спорт", Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 3, page.Total)
+	page, err = store.Tasks(ctx, db.TaskQuery{Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, 5, page.Total)
+
+	// Добавленная задача не сдвигает следующую страницу
+	storeAdd(t, store, db.Task{Date: "20240101", Title: "Йога"})
+	page, err = store.Tasks(ctx, db.TaskQuery{Limit: 2, Cursor: page.NextCursor})
+	require.NoError(t, err)
+	require.Len(t, page.Tasks, 2)
+	assert.Equal(t, "Атлетика", page.Tasks[0].Title)
+	assert.Equal(t, 6, page.Total)
+
+	// Курсор подходит только к выборке с тем же порядком
+	_, err = store.Tasks(ctx, db.TaskQuery{Limit: 2, Sort: db.SortTitle, Cursor: page.NextCursor})
+	assert.ErrorIs(t, err, db.ErrInvalidCursor)
+	_, err = store.Tasks(ctx, db.TaskQuery{Limit: 2, Cursor: "не курсор"})
+	assert.ErrorIs(t, err, db.ErrInvalidCursor)
+	_, err = store.Tasks(ctx, db.TaskQuery{Limit: 2, Sort: "priority"})
+	assert.ErrorIs(t, err, db.ErrInvalidSort)
+}
+
+func testStoreFilters(t *testing.T, store db.TaskStore) {
+	storeAdd(t, store, db.Task{Date: "20240110", Title: "Отчёт за квартал", Comment: "до обеда"})
+	storeAdd(t, store, db.Task{Date: "20240115", Title: "Планёрка", Repeat: "w 1"})
+	storeAdd(t, store, db.Task{Date: "20240120", Title: "Отчёт по проекту", Repeat: "d 7", Comment: "черновик"})
+	storeAdd(t, store, db.Task{Date: "20240125", Title: "Отпуск"})
+
+	yes, no := true, false
+	titles := func(q db.TaskQuery) []string {
+		page, err := store.Tasks(ctx, q)
+		require.NoError(t, err)
+		list := make([]string, 0, len(page.Tasks))
+		for _, task := range page.Tasks {
+			list = append(list, task.Title)
+		}
+		assert.Equal(t, len(list), page.Total)
+		return list
+	}
+
+	assert.Equal(t, []string{"Планёрка", "Отчёт по проекту"}, titles(db.TaskQuery{From: "20240115", To: "20240120"}))
+	assert.Equal(t, []string{"Отчёт по проекту", "Отпуск"}, titles(db.TaskQuery{From: "20240116"}))
+	assert.Equal(t, []string{"Отчёт за квартал"}, titles(db.TaskQuery{To: "20240114"}))
+	assert.Equal(t, []string{"Планёрка", "Отчёт по проекту"}, titles(db.TaskQuery{Repeating: &yes}))
+	assert.Equal(t, []string{"Отчёт за квартал", "Отпуск"}, titles(db.TaskQuery{Repeating: &no}))
+	assert.Equal(t, []string{"Отчёт за квартал", "Отчёт по проекту"}, titles(db.TaskQuery{HasComment: &yes}))
+	assert.Equal(t, []string{"Планёрка", "Отпуск"}, titles(db.TaskQuery{HasComment: &no}))
+
+	// Условия сочетаются между собой и с поиском
+	assert.Equal(t, []string{"Отчёт по проекту"}, titles(db.TaskQuery{Search: "отчёт", Repeating: &yes}))
+	assert.Equal(t, []string{"Отчёт за квартал"}, titles(db.TaskQuery{Search: "отчёт", To: "20240119"}))
+	assert.Equal(t, []string{}, titles(db.TaskQuery{Search: "20.01.2024", Repeating: &no}))
+	assert.Equal(t, []string{}, titles(db.TaskQuery{From: "20240121", To: "20240119"}))
+}
+
+func testStoreQuery(t *testing.T, store db.TaskStore) {
+	storeAdd(t, store, db.Task{Date: "20240110", Title: "Отчёт за квартал", Comment: "до обеда"})
+	storeAdd(t, store, db.Task{Date: "20240115", Title: "Планёрка", Repeat: "w 1", Comment: "отчёт отдела"})
+	storeAdd(t, store, db.Task{Date: "20240120", Title: "Отчёт по проекту", Repeat: "d 7", Comment: "черновик"})
+	storeAdd(t, store, db.Task{Date: "20240125", Title: "Отпуск"})
+	storeAdd(t, store, db.Task{Date: "20240130", Title: "Сверка", Repeat: "mw 2-2"})
+	storeAdd(t, store, db.Task{Date: "20240131", Title: "Аренда", Repeat: "FREQ=MONTHLY;BYMONTHDAY=-1"})
+
+	titles := func(s string) []string {
+		filter, err := taskql.Parse(s)
+		require.NoError(t, err, s)
+		page, err := store.Tasks(ctx, db.TaskQuery{Filter: filter})
+		require.NoError(t, err, s)
+		list := make([]string, 0, len(page.Tasks))
+		for _, task := range page.Tasks {
+			list = append(list, task.Title)
+		}
+		return list
+	}
+
+	assert.Equal(t, []string{"Отчёт за квартал", "Отчёт по проекту"}, titles("title:отч"))
+	assert.Equal(t, []string{"Отчёт за квартал", "Планёрка", "Отчёт по проекту"}, titles("отчёт"))
+	assert.Equal(t, []string{"Планёрка"}, titles("comment:отчёт"))
+	assert.Equal(t, []string{"Планёрка"}, titles("repeat:w"))
+	assert.Equal(t, []string{"Отчёт за квартал", "Отпуск", "Сверка", "Аренда"}, titles("-repeat:w -repeat:d"))
+	assert.Equal(t, []string{"Сверка"}, titles("repeat:MW"))
+	assert.Equal(t, []string{}, titles("repeat:m"))
+	assert.Equal(t, []string{"Аренда"}, titles("repeat:freq=monthly"))
+	assert.Equal(t, []string{"Отчёт за квартал", "Планёрка"}, titles("due<20.01.2024"))
+	assert.Equal(t, []string{"Отчёт по проекту", "Отпуск", "Сверка", "Аренда"}, titles("due>=20240120"))
+	assert.Equal(t, []string{"Отчёт по проекту"}, titles("date:20240120"))
+	assert.Equal(t, []string{"Отчёт за квартал"}, titles(`title:"отчёт квартал" -comment:черновик`))
+	assert.Equal(t, []string{"Планёрка", "Отчёт по проекту"}, titles("отчёт -title:квартал due>20240110"))
+	assert.Equal(t, []string{}, titles("title:командировка"))
+}
+
+func testStoreSaved(t *testing.T, store db.TaskStore) {
+	filters, err := store.Filters(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, filters)
+
+	require.NoError(t, store.AddFilter(ctx, db.Filter{Name: "отчёты", Query: "title:отчёт"}))
+	require.NoError(t, store.AddFilter(ctx, db.Filter{Name: "недельные", Query: "repeat:w"}))
+	assert.ErrorIs(t, store.AddFilter(ctx, db.Filter{Name: "отчёты", Query: "отчёт"}), db.ErrFilterExists)
+
+	filters, err = store.Filters(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []db.Filter{
+		{Name: "недельные", Query: "repeat:w"},
+		{Name: "отчёты", Query: "title:отчёт"},
+	}, filters)
+
+	require.NoError(t, store.UpdateFilter(ctx, db.Filter{Name: "отчёты", Query: "title:отчёт -repeat:d"}))
+	filter, err := store.GetFilter(ctx, "отчёты")
+	require.NoError(t, err)
+	assert.Equal(t, "title:отчёт -repeat:d", filter.Query)
+
+	require.NoError(t, store.DeleteFilter(ctx, "отчёты"))
+	_, err = store.GetFilter(ctx, "отчёты")
+	assert.ErrorIs(t, err, db.ErrFilterNotFound)
+	assert.ErrorIs(t, store.DeleteFilter(ctx, "отчёты"), db.ErrFilterNotFound)
+	assert.ErrorIs(t, store.UpdateFilter(ctx, db.Filter{Name: "отчёты", Query: "отчёт"}), db.ErrFilterNotFound)
+}
+
+func testStoreTags(t *testing.T, store db.TaskStore) {
+	report := db.Task{Date: "20240110", Title: "Отчёт", Tags: []string{"#Работа", "срочно", "работа", " "}}
+	id := storeAdd(t, store, report)
+	storeAdd(t, store, db.Task{Date: "20240115", Title: "Спортзал", Tags: []string{"личное"}})
+	storeAdd(t, store, db.Task{Date: "20240120", Title: "Планёрка", Tags: []string{"работа"}})
+
+	task, err := store.GetTask(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"работа", "срочно"}, task.Tags)
+
+	tags := func() map[string]db.Tag {
+		list, err := store.Tags(ctx)
+		require.NoError(t, err)
+		byName := make(map[string]db.Tag, len(list))
+		for _, tag := range list {
+			byName[tag.Name] = tag
+		}
+		return byName
+	}
+	titles := func(q db.TaskQuery) []string {
+		page, err := store.Tasks(ctx, q)
+		require.NoError(t, err)
+		list := make([]string, 0, len(page.Tasks))
+		for _, task := range page.Tasks {
+			list = append(list, task.Title)
+		}
+		return list
+	}
+	assert.Equal(t, 2, tags()["работа"].Tasks)
+	assert.Equal(t, []string{"Отчёт", "Планёрка"}, titles(db.TaskQuery{Tags: []string{"Работа"}}))
+	assert.Equal(t, []string{"Отчёт"}, titles(db.TaskQuery{Tags: []string{"работа", "срочно"}}))
+	filter, err := taskql.Parse("tag:#работа -tag:срочно")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Планёрка"}, titles(db.TaskQuery{Filter: filter}))
+
+	// Без списка меток задача сохраняет свои метки, пустой список снимает их
+	task.Title = "Отчёт за месяц"
+	task.Tags = nil
+	require.NoError(t, store.UpdateTask(ctx, task))
+	assert.Equal(t, []string{"работа", "срочно"}, task.Tags)
+	task.Tags = []string{"срочно", "отчёты"}
+	require.NoError(t, store.UpdateTask(ctx, task))
+	task, err = store.GetTask(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"отчёты", "срочно"}, task.Tags)
+
+	// Новая метка, переименование и цвет
+	tag := db.Tag{Name: "Дом", Color: "#00ff00"}
+	require.NoError(t, store.AddTag(ctx, &tag))
+	assert.Equal(t, db.Tag{ID: tag.ID, Name: "дом", Color: "#00ff00"}, tags()["дом"])
+	assert.ErrorIs(t, store.AddTag(ctx, &db.Tag{Name: "дом"}), db.ErrTagExists)
+
+	urgent := tags()["срочно"]
+	urgent.Name, urgent.Color = "важно", "#ff0000"
+	require.NoError(t, store.UpdateTag(ctx, &urgent))
+	assert.Equal(t, 1, urgent.Tasks)
+	renamed, err := store.GetTask(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"важно", "отчёты"}, renamed.Tags)
+	assert.Greater(t, renamed.Version, task.Version)
+	urgent.Name = "дом"
+	assert.ErrorIs(t, store.UpdateTag(ctx, &urgent), db.ErrTagExists)
+	assert.ErrorIs(t, store.UpdateTag(ctx, &db.Tag{ID: 999999, Name: "нет"}), db.ErrTagNotFound)
+
+	// Объединение: задачи метки "отчёты" получают метку "работа"
+	require.NoError(t, store.MergeTags(ctx, tags()["отчёты"].ID, tags()["работа"].ID))
+	assert.NotContains(t, tags(), "отчёты")
+	assert.Equal(t, 2, tags()["работа"].Tasks)
+	assert.Equal(t, []string{"Отчёт за месяц", "Планёрка"}, titles(db.TaskQuery{Tags: []string{"работа"}}))
+	assert.ErrorIs(t, store.MergeTags(ctx, 999999, tags()["работа"].ID), db.ErrTagNotFound)
+
+	// Удаление снимает метку с задач, удаление задачи — её метки
+	require.NoError(t, store.DeleteTag(ctx, tags()["важно"].ID))
+	task, err = store.GetTask(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"работа"}, task.Tags)
+	assert.ErrorIs(t, store.DeleteTag(ctx, 999999), db.ErrTagNotFound)
+	require.NoError(t, store.DeleteTask(ctx, id, 0))
+	assert.Equal(t, 1, tags()["работа"].Tasks)
+}
+
+func testStoreDates(t *testing.T, store db.TaskStore) {
+	id := storeAdd(t, store, db.Task{Date: "20240126", Title: "Планёрка", Repeat: "d 1 count 3", Remaining: 3})
+	task, err := store.GetTask(ctx, id)
+	require.NoError(t, err)
+
+	require.NoError(t, store.UpdateDate(ctx, "20240127", "", task))
+	stored, err := store.GetTask(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "20240127", stored.Date)
+	assert.Equal(t, int64(2), stored.Remaining)
+
+	// Перенос от устаревшей даты — конфликт
+	assert.ErrorIs(t, store.UpdateDate(ctx, "20240128", "", task), db.ErrConflict)
+	assert.ErrorIs(t, store.SkipDate(ctx, task, 0, "20240128"), db.ErrConflict)
+	assert.ErrorIs(t, store.UpdateDate(ctx, "20240128", "", &db.Task{ID: 999999}), db.ErrNotFound)
+
+	// Версия из запроса должна совпадать с прочитанной
+	assert.ErrorIs(t, store.SkipDate(ctx, stored, stored.Version-1, "20240128"), db.ErrConflict)
+	version := stored.Version
+	require.NoError(t, store.SkipDate(ctx, stored, version, "20240128"))
+	assert.Equal(t, version+1, stored.Version)
+	stored, err = store.GetTask(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "20240128", stored.Date)
+	assert.Equal(t, int64(2), stored.Remaining)
+
+	require.NoError(t, store.SkipDate(ctx, stored, 0, "20240130"))
+	dates, err := store.Exceptions(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"20240127", "20240128"}, dates)
+
+	_, err = store.Exceptions(ctx, "abc")
+	assert.ErrorIs(t, err, db.ErrInvalidID)
+
+	// Вместе с задачей удаляются и её пропуски
+	require.NoError(t, store.DeleteTask(ctx, id, 0))
+	dates, err = store.Exceptions(ctx, id)
+	require.NoError(t, err)
+	assert.Empty(t, dates)
+}
+
+func testStoreHolidays(t *testing.T, store db.TaskStore) {
+	require.NoError(t, store.SaveHolidays(ctx, []db.Holiday{
+		{Date: "20240501", Title: "Праздник весны и труда"},
+		{Date: "20240427", Title: "Рабочая суббота", Workday: true},
+	}, false))
+	require.NoError(t, store.SaveHolidays(ctx, []db.Holiday{
+		{Date: "20240501", Title: "1 мая"},
+		{Date: "20240509", Title: "День Победы"},
+	}, false))
+
+	holidays, err := store.Holidays(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []db.Holiday{
+		{Date: "20240427", Title: "Рабочая суббота", Workday: true},
+		{Date: "20240501", Title: "1 мая"},
+		{Date: "20240509", Title: "День Победы"},
+	}, holidays)
+
+	require.NoError(t, store.DeleteHolidays(ctx, "20240501"))
+	assert.ErrorIs(t, store.DeleteHolidays(ctx, "20240501"), db.ErrHolidayNotFound)
+
+	require.NoError(t, store.SaveHolidays(ctx, []db.Holiday{{Date: "20250101", Title: "Новый год"}}, true))
+	holidays, err = store.Holidays(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []db.Holiday{{Date: "20250101", Title: "Новый год"}}, holidays)
+
+	require.NoError(t, store.DeleteHolidays(ctx, ""))
+	holidays, err = store.Holidays(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, holidays)
+}
+
+func testStoreConcurrent(t *testing.T, store db.TaskStore) {
+	id := storeAdd(t, store, db.Task{Date: "20240126", Title: "Полить цветы", Repeat: "d 1"})
+	task, err := store.GetTask(ctx, id)
+	require.NoError(t, err)
+
+	// Из нескольких переносов одной и той же даты выполняется только один
+	const n = 8
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		updated   int
+		conflicts int
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := store.UpdateDate(ctx, "20240127", "", task)
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				updated++
+			} else if assert.ErrorIs(t, err, db.ErrConflict) {
+				conflicts++
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, updated)
+	assert.Equal(t, n-1, conflicts)
+}
+
+func testStoreVersions(t *testing.T, store db.TaskStore) {
+	id := storeAdd(t, store, db.Task{Date: "20240126", Title: "Черновик"})
+	task, err := store.GetTask(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), task.Version)
+
+	// Каждое изменение увеличивает версию, сохранение по старой версии — конфликт
+	stale := *task
+	task.Title = "Чистовик"
+	require.NoError(t, store.UpdateTask(ctx, task))
+	assert.Equal(t, int64(2), task.Version)
+	stale.Title = "Другая правка"
+	assert.ErrorIs(t, store.UpdateTask(ctx, &stale), db.ErrConflict)
+
+	require.NoError(t, store.UpdateDate(ctx, "20240127", "", task))
+	stored, err := store.GetTask(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "Чистовик", stored.Title)
+	assert.Equal(t, int64(3), stored.Version)
+
+	// Без версии задача сохраняется в любом случае
+	stale.Version = 0
+	require.NoError(t, store.UpdateTask(ctx, &stale))
+	assert.Equal(t, int64(4), stale.Version)
+
+	assert.ErrorIs(t, store.DeleteTask(ctx, id, 3), db.ErrConflict)
+	require.NoError(t, store.DeleteTask(ctx, id, 4))
+	assert.ErrorIs(t, store.DeleteTask(ctx, id, 4), db.ErrNotFound)
+}
+
+func testStoreComplete(t *testing.T, store db.TaskStore) {
+	id := storeAdd(t, store, db.Task{Date: "20240126", Title: "Полив", Repeat: "d 1 count 2", Remaining: 2})
+	task, err := store.GetTask(ctx, id)
+	require.NoError(t, err)
+	require.NoError(t, store.SkipDate(ctx, task, 0, "20240127"))
+
+	// Функция расчёта получает задачу и её пропущенные даты
+	next := func(date string) db.NextFunc {
+		return func(task *db.Task, except []string) (string, string, error) {
+			assert.Equal(t, []string{"20240126"}, except)
+			return date, "", nil
+		}
+	}
+	_, err = store.CompleteTask(ctx, id, 1, next("20240128"))
+	assert.ErrorIs(t, err, db.ErrConflict)
+	_, err = store.CompleteTask(ctx, "999999", 0, next("20240128"))
+	assert.ErrorIs(t, err, db.ErrNotFound)
+
+	task, err = store.CompleteTask(ctx, id, 2, next("20240128"))
+	require.NoError(t, err)
+	assert.Equal(t, "20240128", task.Date)
+	assert.Equal(t, int64(1), task.Remaining)
+	assert.Equal(t, int64(3), task.Version)
+
+	// Ошибка расчёта не меняет задачу
+	_, err = store.CompleteTask(ctx, id, 0, func(*db.Task, []string) (string, string, error) {
+		return "", "", db.ErrInvalidID
+	})
+	assert.ErrorIs(t, err, db.ErrInvalidID)
+	stored, err := store.GetTask(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, *task, *stored)
+
+	// Пустая дата — задача выполнена окончательно и удаляется вместе с пропусками
+	task, err = store.CompleteTask(ctx, id, 0, next(""))
+	require.NoError(t, err)
+	assert.Nil(t, task)
+	_, err = store.GetTask(ctx, id)
+	assert.ErrorIs(t, err, db.ErrNotFound)
+	dates, err := store.Exceptions(ctx, id)
+	require.NoError(t, err)
+	assert.Empty(t, dates)
+}
+
+func testStoreCanceled(t *testing.T, store db.TaskStore) {
+	id := storeAdd(t, store, db.Task{Date: "20240126", Title: "Отменённый запрос"})
+
+	// Запрос с отменённым контекстом не выполняется и ничего не меняет
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	_, err := store.GetTask(canceled, id)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = store.Tasks(canceled, db.TaskQuery{Limit: 10})
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = store.AddTask(canceled, &db.Task{Date: "20240126", Title: "Лишняя"})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, store.DeleteTask(canceled, id, 0), context.Canceled)
+
+	// Истёкший срок отличается от отмены
+	expired, cancel := context.WithDeadline(ctx, time.Now().Add(-time.Second))
+	defer cancel()
+	_, err = store.Holidays(expired)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	page, err := store.Tasks(ctx, db.TaskQuery{Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, page.Tasks, 1)
+}
@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type jsonTag struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color"`
+	Tasks int    `json:"tasks"`
+}
+
+func TestTags(t *testing.T) {
+	// Метки уникальны для запуска: база может остаться от прошлых тестов
+	suffix := strconv.FormatInt(time.Now().UnixNano(), 36)
+	work, home, urgent := "работа-"+suffix, "дом-"+suffix, "срочно-"+suffix
+	date := time.Now().AddDate(0, 0, 70).Format(`20060102`)
+
+	tagged := func(params string) []string {
+		status, body, err := request("api/tasks?limit=100&"+params, nil, http.MethodGet)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, status, params)
+		var m struct {
+			Tasks []map[string]any `json:"tasks"`
+		}
+		assert.NoError(t, json.Unmarshal(body, &m))
+		titles := make([]string, 0)
+		for _, task := range m.Tasks {
+			titles = append(titles, task["title"].(string))
+		}
+		return titles
+	}
+	tags := func() map[string]jsonTag {
+		status, body, err := request("api/tags", nil, http.MethodGet)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, status)
+		var m map[string][]jsonTag
+		assert.NoError(t, json.Unmarshal(body, &m))
+		byName := make(map[string]jsonTag)
+		for _, tag := range m["tags"] {
+			byName[tag.Name] = tag
+		}
+		return byName
+	}
+
+	ret, err := postJSON("api/task", map[string]any{
+		"date": date, "title": "Квартальный отчёт", "tags": []string{"#" + work, urgent},
+	}, http.MethodPost)
+	require.NoError(t, err)
+	id := strconv.FormatInt(int64(ret["id"].(float64)), 10)
+	ret, err = postJSON("api/task", map[string]any{
+		"date": date, "title": "Починить кран", "tags": []string{home},
+	}, http.MethodPost)
+	require.NoError(t, err)
+	homeTask := strconv.FormatInt(int64(ret["id"].(float64)), 10)
+
+	task, err := postJSON("api/task?id="+id, nil, http.MethodGet)
+	require.NoError(t, err)
+	assert.Equal(t, []any{work, urgent}, task["tags"])
+	assert.Equal(t, []string{"Квартальный отчёт"}, tagged("tag="+url.QueryEscape(work)))
+	assert.Equal(t, []string{"Квартальный отчёт"}, tagged("search="+url.QueryEscape("tag:"+urgent)))
+	assert.Equal(t, []string{}, tagged("tag="+url.QueryEscape(work)+"&tag="+url.QueryEscape(home)))
+
+	// PUT без tags не меняет метки задачи
+	_, err = postJSON("api/task", map[string]any{"id": id, "date": date, "title": "Годовой отчёт"}, http.MethodPut)
+	require.NoError(t, err)
+	task, err = postJSON("api/task?id="+id, nil, http.MethodGet)
+	require.NoError(t, err)
+	assert.Equal(t, []any{work, urgent}, task["tags"])
+
+	// Цвет и переименование
+	tag := tags()[urgent]
+	status, body, err := request("api/tags", map[string]any{"id": tag.ID, "name": "Важно-" + suffix, "color": "#FFAA00"}, http.MethodPut)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status, string(body))
+	assert.Equal(t, jsonTag{ID: tag.ID, Name: "важно-" + suffix, Color: "#ffaa00", Tasks: 1}, tags()["важно-"+suffix])
+	for _, v := range []map[string]any{
+		{"name": "", "color": ""},
+		{"name": work, "color": "red"},
+		{"name": work, "color": "#12345g"},
+	} {
+		status, _, err = request("api/tags", v, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, status, v)
+	}
+	status, _, err = request("api/tags", map[string]any{"name": work}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, status)
+
+	// Объединение меток
+	status, _, err = request("api/tags/merge", map[string]any{"from": tags()[home].ID, "into": tags()[work].ID}, http.MethodPost)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	assert.NotContains(t, tags(), home)
+	assert.Equal(t, 2, tags()[work].Tasks)
+	assert.Equal(t, []string{"Годовой отчёт", "Починить кран"}, tagged("tag="+url.QueryEscape(work)+"&sort=created"))
+
+	// Удаление метки
+	status, body, err = request("api/tags?id="+tags()[work].ID, nil, http.MethodDelete)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{}`, string(body))
+	task, err = postJSON("api/task?id="+homeTask, nil, http.MethodGet)
+	require.NoError(t, err)
+	assert.NotContains(t, task, "tags")
+	status, _, err = request("api/tags?id=999999999", nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, status)
+}
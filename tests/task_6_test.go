@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
 	"testing"
 	"time"
@@ -48,6 +49,120 @@ func TestTask(t *testing.T) {
 	assert.Equal(t, task.repeat, m["repeat"])
 }
 
+func TestTaskTime(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	today := time.Now().Format(`20060102`)
+	ret, err := postJSON("api/task", map[string]any{
+		"date":   today,
+		"time":   "9:30",
+		"title":  "Планёрка",
+		"repeat": "w 1,2,3,4,5,6,7",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	id := fmt.Sprint(ret["id"])
+
+	body, err := requestJSON("api/task?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	var m map[string]string
+	assert.NoError(t, json.Unmarshal(body, &m))
+	assert.Equal(t, "09:30", m["time"])
+	assert.Equal(t, today, m["date"])
+
+	// Повторение в течение дня сдвигает задачу на ближайшее время после текущего
+	now := time.Now().UTC()
+	next := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	for !next.After(now.Truncate(time.Minute)) {
+		next = next.Add(6 * time.Hour)
+	}
+	ret, err = postJSON("api/task", map[string]any{
+		"date":   next.Format(`20060102`),
+		"time":   "00:00",
+		"title":  "Проверить очередь",
+		"repeat": "h 6",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	id = fmt.Sprint(ret["id"])
+
+	var stored Task
+	err = db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, next.Format(`20060102 15:04`), stored.Date+" "+stored.Time)
+
+	ret, err = postJSON("api/task", map[string]any{
+		"date":  today,
+		"time":  "25:00",
+		"title": "Ошибка",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+}
+
+func TestRepeatText(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	id := addTask(t, task{
+		date:   time.Now().Format(`20060102`),
+		title:  "Отчёт",
+		repeat: "m -1,15 3,6,9",
+	})
+
+	body, err := requestJSON("api/task?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	var m map[string]string
+	assert.NoError(t, json.Unmarshal(body, &m))
+	assert.Equal(t, "15-го и в последний день марта, июня и сентября", m["repeat_text"])
+
+	body, err = requestJSON("api/task?lang=en&id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(body, &m))
+	assert.Equal(t, "on the 15th and the last day of March, June and September", m["repeat_text"])
+}
+
+func TestNaturalInput(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	tomorrow := time.Now().AddDate(0, 0, 1).Format(`20060102`)
+	ret, err := postJSON("api/task", map[string]any{
+		"date":   "завтра",
+		"title":  "Тренировка",
+		"repeat": "каждый понедельник и пятницу",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, tomorrow, ret["date"])
+	assert.Equal(t, "w 1,5", ret["repeat"])
+
+	var stored Task
+	err = db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, fmt.Sprint(ret["id"]))
+	assert.NoError(t, err)
+	assert.Equal(t, tomorrow, stored.Date)
+	assert.Equal(t, "w 1,5", stored.Repeat)
+
+	body, err := requestJSON("api/parse?date="+url.QueryEscape("через 3 дня")+
+		"&repeat="+url.QueryEscape("every 2 weeks"), nil, http.MethodGet)
+	assert.NoError(t, err)
+	var m map[string]string
+	assert.NoError(t, json.Unmarshal(body, &m))
+	assert.Equal(t, time.Now().AddDate(0, 0, 3).Format(`20060102`), m["date"])
+	assert.Equal(t, "d 14", m["repeat"])
+	assert.NotEmpty(t, m["repeat_text"])
+
+	for _, repeat := range []string{"every other monday", "через понедельник", "every 2 weeks on monday"} {
+		body, err = requestJSON("api/parse?repeat="+url.QueryEscape(repeat), nil, http.MethodGet)
+		assert.NoError(t, err)
+		assert.NoError(t, json.Unmarshal(body, &m))
+		assert.Equal(t, "w 1 /2", m["repeat"], repeat)
+	}
+
+	body, err = requestJSON("api/parse?repeat=ooops", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(body, &m))
+	assert.NotEmpty(t, m["error"])
+}
+
 type fulltask struct {
 	id string
 	task
@@ -3,6 +3,7 @@ package tests
 import (
 	"encoding/json"
 	"net/http"
+	"sync"
 	"testing"
 	"time"
 
@@ -52,6 +53,125 @@ func TestDone(t *testing.T) {
 	}
 }
 
+func TestDoneSeries(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	id := addTask(t, task{
+		date:   now.Format(`20060102`),
+		title:  "Тренировка",
+		repeat: "d 2 count 3",
+	})
+
+	for i := 0; i < 2; i++ {
+		ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+
+		var task Task
+		err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+		assert.NoError(t, err)
+		now = now.AddDate(0, 0, 2)
+		assert.Equal(t, now.Format(`20060102`), task.Date)
+		assert.Equal(t, int64(2-i), task.Remaining)
+	}
+
+	ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	notFoundTask(t, id)
+
+	id = addTask(t, task{
+		title:  "Отчёт",
+		repeat: "d 1 until " + time.Now().AddDate(0, 0, 1).Format(`20060102`),
+	})
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	notFoundTask(t, id)
+}
+
+func TestDoneFromDone(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	id := addTask(t, task{
+		date:   now.AddDate(0, 0, 10).Format(`20060102`),
+		title:  "Полить цветы",
+		repeat: "d 3 from done",
+	})
+
+	body, err := requestJSON("api/task?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	var ret map[string]string
+	assert.NoError(t, json.Unmarshal(body, &ret))
+	assert.Equal(t, "done", ret["repeat_mode"])
+
+	// Следующая дата отсчитывается от выполнения, а не от даты задачи
+	next := now.AddDate(0, 0, 3).Format(`20060102`)
+	for i := 0; i < 2; i++ {
+		ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+
+		var stored Task
+		err = db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, id)
+		assert.NoError(t, err)
+		assert.Equal(t, next, stored.Date)
+	}
+}
+
+func TestSkip(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	today := now.Format(`20060102`)
+	id := addTask(t, task{
+		date:   today,
+		title:  "Планёрка",
+		repeat: "d 2",
+	})
+
+	ret, err := postJSON("api/task/skip?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	next := now.AddDate(0, 0, 2).Format(`20060102`)
+	assert.Equal(t, next, ret["date"])
+
+	var stored Task
+	err = db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, next, stored.Date)
+
+	var skipped []string
+	err = db.Select(&skipped, `SELECT date FROM exceptions WHERE task_id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{today}, skipped)
+
+	id = addTask(t, task{
+		title: "Разовая задача",
+	})
+	ret, err = postJSON("api/task/skip?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+	assert.Equal(t, "skip_not_allowed", ret["code"])
+
+	id = addTask(t, task{
+		date:   today,
+		title:  "Каждый час",
+		repeat: "h 1",
+	})
+	status, body, err := request("api/task/skip?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, string(body), `"code":"skip_not_allowed"`)
+}
+
 func TestDelTask(t *testing.T) {
 	db := openDB(t)
 	defer db.Close()
@@ -73,3 +193,139 @@ func TestDelTask(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotEmpty(t, ret)
 }
+
+func TestDoneConcurrent(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	id := addTask(t, task{
+		date:   now.Format(`20060102`),
+		title:  "Утренняя зарядка",
+		repeat: "d 1",
+	})
+
+	// Параллельные отметки выполнения: каждая либо сдвигает задачу
+	// на следующую дату, либо получает конфликт
+	const n = 8
+	statuses := make(chan int, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status, _, err := request("api/task/done?id="+id, nil, http.MethodPost)
+			assert.NoError(t, err)
+			statuses <- status
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	done := 0
+	for status := range statuses {
+		if status == http.StatusOK {
+			done++
+			continue
+		}
+		assert.Equal(t, http.StatusConflict, status)
+	}
+
+	var stored Task
+	err := db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, now.AddDate(0, 0, done).Format(`20060102`), stored.Date)
+}
+
+func TestTaskETag(t *testing.T) {
+	now := time.Now()
+	id := addTask(t, task{
+		date:   now.Format(`20060102`),
+		title:  "Созвон с командой",
+		repeat: "d 1",
+	})
+
+	etag := func() string {
+		status, header, _, err := requestHeader("api/task?id="+id, nil, http.MethodGet, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, status)
+		assert.NotEmpty(t, header.Get("ETag"))
+		return header.Get("ETag")
+	}
+	ifMatch := func(tag string) http.Header {
+		return http.Header{"If-Match": {tag}}
+	}
+	edit := map[string]any{
+		"id":     id,
+		"date":   now.Format(`20060102`),
+		"title":  "Созвон с командой в 10:00",
+		"repeat": "d 1",
+	}
+
+	// Правка по актуальной версии проходит и возвращает новую версию
+	v1 := etag()
+	status, header, _, err := requestHeader("api/task", edit, http.MethodPut, ifMatch(v1))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	v2 := header.Get("ETag")
+	assert.NotEqual(t, v1, v2)
+	assert.Equal(t, v2, etag())
+
+	// Вторая правка по той же загруженной версии не затирает первую
+	for _, tag := range []string{v1, `W/` + v2, `"abc"`} {
+		status, _, body, err := requestHeader("api/task", edit, http.MethodPut, ifMatch(tag))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusPreconditionFailed, status, tag)
+		var resp map[string]any
+		assert.NoError(t, json.Unmarshal(body, &resp))
+		assert.Equal(t, "precondition_failed", resp["code"])
+	}
+	status, _, _, err = requestHeader("api/task/done?id="+id, nil, http.MethodPost, ifMatch(v1))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusPreconditionFailed, status)
+	status, _, _, err = requestHeader("api/task?id="+id, nil, http.MethodDelete, ifMatch(v1))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusPreconditionFailed, status)
+	status, _, _, err = requestHeader("api/task/skip?id="+id, nil, http.MethodPost, ifMatch(v1))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusPreconditionFailed, status)
+
+	// Пропуск по актуальной версии тоже возвращает новую версию
+	status, header, _, err = requestHeader("api/task/skip?id="+id, nil, http.MethodPost, ifMatch(v2))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	v3 := header.Get("ETag")
+	assert.NotEqual(t, v2, v3)
+	assert.Equal(t, v3, etag())
+	v2 = v3
+
+	// Из параллельных отметок с одной версией выполняется только одна
+	const n = 8
+	statuses := make(chan int, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status, _, _, err := requestHeader("api/task/done?id="+id, nil, http.MethodPost, ifMatch(v2))
+			assert.NoError(t, err)
+			statuses <- status
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+	done := 0
+	for status := range statuses {
+		if status == http.StatusOK {
+			done++
+			continue
+		}
+		assert.Equal(t, http.StatusPreconditionFailed, status)
+	}
+	assert.Equal(t, 1, done)
+
+	status, _, _, err = requestHeader("api/task?id="+id, nil, http.MethodDelete, ifMatch("*"))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	notFoundTask(t, id)
+}
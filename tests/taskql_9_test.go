@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"testing"
+
+	"go1f/pkg/taskql"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskQLParse(t *testing.T) {
+	q, err := taskql.Parse(`title:"Отчёт за квартал" -repeat:W due<=01.02.2026 позвонить`)
+	require.NoError(t, err)
+	assert.Equal(t, []taskql.Term{
+		{Field: taskql.FieldTitle, Op: ":", Value: "Отчёт за квартал", Words: []string{"отчёт", "за", "квартал"}, Pos: 1},
+		{Field: taskql.FieldRepeat, Op: ":", Value: "w", Not: true, Pos: 26},
+		{Field: taskql.FieldDate, Op: "<=", Value: "20260201", Pos: 36},
+		{Op: ":", Value: "позвонить", Words: []string{"позвонить"}, Pos: 52},
+	}, q.Terms)
+
+	assert.True(t, taskql.IsQuery("отчёт due>20260101"))
+	assert.True(t, taskql.IsQuery("-repeat:d"))
+	assert.False(t, taskql.IsQuery("отчёт за квартал"))
+	assert.False(t, taskql.IsQuery("встреча в 10:00"))
+	assert.False(t, taskql.IsQuery("20.01.2024"))
+}
+
+func TestTaskQLErrors(t *testing.T) {
+	tbl := []struct {
+		query string
+		pos   int
+	}{
+		{"", 1},
+		{"   ", 1},
+		{"priority:high", 1},
+		{"отчёт статус:готово", 7},
+		{"title:", 7},
+		{"due<2026", 5},
+		{"due:31.02.2026", 5},
+		{"repeat>d", 1},
+		{"отчёт title<план", 7},
+		{`comment:"без кавычки`, 9},
+		{"отчёт - план", 7},
+		{"title:!!!", 7},
+		{"tag:#", 5},
+		{"tag>работа", 1},
+	}
+	for _, v := range tbl {
+		_, err := taskql.Parse(v.query)
+		var syntax *taskql.SyntaxError
+		if assert.ErrorAs(t, err, &syntax, v.query) {
+			assert.Equal(t, v.pos, syntax.Pos, "%q: %v", v.query, err)
+		}
+	}
+}
+
+func TestTaskQLMatch(t *testing.T) {
+	task := taskql.Fields{Title: "Позвонить в банк", Comment: "Уточнить тариф", Repeat: "m 1", Date: "20260115",
+		Tags: []string{"работа", "финансы"}}
+	tbl := []struct {
+		query string
+		match bool
+	}{
+		{"позв", true},
+		{"банк тариф", true},
+		{"title:тариф", false},
+		{"comment:тариф", true},
+		{"-comment:тариф", false},
+		{"repeat:m", true},
+		{"repeat:w", false},
+		{"due:20260115", true},
+		{"due<20260115", false},
+		{"due<=15.01.2026 due>=20260101", true},
+		{`title:"в банк"`, true},
+		{"title:банкомат", false},
+		{"tag:работа", true},
+		{"tag:#Финансы -tag:дом", true},
+		{"tag:раб", false},
+	}
+	for _, v := range tbl {
+		q, err := taskql.Parse(v.query)
+		require.NoError(t, err, v.query)
+		assert.Equal(t, v.match, q.Match(task), v.query)
+	}
+
+	// repeat: сравнивает вид правила целиком, а не начало записи
+	for _, v := range []struct {
+		repeat string
+		query  string
+		match  bool
+	}{
+		{"mw 2-2", "repeat:m", false},
+		{"mw 2-2", "repeat:mw", true},
+		{"mw 2-2", "repeat:MW", true},
+		{"m 1", "repeat:mw", false},
+		{"min 30", "repeat:m", false},
+		{"FREQ=WEEKLY;BYDAY=MO", "repeat:freq=weekly", true},
+		{"FREQ=WEEKLY;BYDAY=MO", "repeat:FREQ=WEEK", false},
+		{"", "repeat:w", false},
+		{"", "-repeat:w", true},
+	} {
+		q, err := taskql.Parse(v.query)
+		require.NoError(t, err, v.query)
+		assert.Equal(t, v.match, q.Match(taskql.Fields{Repeat: v.repeat}), "%s %q", v.query, v.repeat)
+	}
+
+	q, err := taskql.Parse("title:отчёт -repeat:d due>20260101")
+	require.NoError(t, err)
+	where, args := q.Where()
+	assert.Len(t, where, 3)
+	assert.Equal(t, []interface{}{`title : ("отчёт"*)`, "d", "20260101"}, args)
+
+	saved, err := taskql.Parse("repeat:w")
+	require.NoError(t, err)
+	assert.Len(t, q.And(saved).Terms, 4)
+	assert.Same(t, saved, (*taskql.Query)(nil).And(saved))
+}
@@ -4,10 +4,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func addTask(t *testing.T, task task) string {
@@ -24,6 +27,13 @@ func addTask(t *testing.T, task task) string {
 	return id
 }
 
+// tasksPage — ответ GET /api/tasks
+type tasksPage struct {
+	Tasks      []map[string]string `json:"tasks"`
+	Total      int                 `json:"total"`
+	NextCursor string              `json:"next_cursor"`
+}
+
 func getTasks(t *testing.T, search string) []map[string]string {
 	url := "api/tasks"
 	if Search {
@@ -32,10 +42,10 @@ func getTasks(t *testing.T, search string) []map[string]string {
 	body, err := requestJSON(url, nil, http.MethodGet)
 	assert.NoError(t, err)
 
-	var m map[string][]map[string]string
+	var m tasksPage
 	err = json.Unmarshal(body, &m)
 	assert.NoError(t, err)
-	return m["tasks"]
+	return m.Tasks
 }
 
 func TestTasks(t *testing.T) {
@@ -106,3 +116,197 @@ func TestTasks(t *testing.T) {
 	assert.Equal(t, len(tasks), 3)
 
 }
+
+func TestTasksSearch(t *testing.T) {
+	if !Search {
+		return
+	}
+	date := time.Now().AddDate(0, 0, 5).Format(`20060102`)
+	addTask(t, task{
+		date:    date,
+		title:   "Купить <подарок>",
+		comment: "Книгу или ПЛАТОК",
+	})
+
+	// Поиск по словам без учёта регистра кириллицы, найденные слова
+	// выделены во фрагменте, текст задачи экранирован
+	tasks := getTasks(t, "купить")
+	assert.Len(t, tasks, 1)
+	assert.Equal(t, "<mark>Купить</mark> &lt;подарок&gt;", tasks[0]["snippet"])
+
+	tasks = getTasks(t, "плат")
+	assert.Len(t, tasks, 1)
+	assert.Equal(t, "Книгу или <mark>ПЛАТОК</mark>", tasks[0]["snippet"])
+
+	tasks = getTasks(t, "")
+	for _, task := range tasks {
+		assert.Empty(t, task["snippet"])
+	}
+}
+
+func TestTasksPages(t *testing.T) {
+	date := time.Now().AddDate(0, 0, 10).Format(`20060102`)
+	for _, title := range []string{"Страница 1", "Страница 2", "Страница 3"} {
+		addTask(t, task{date: date, title: title, comment: "постранично"})
+	}
+
+	// Страницы по две задачи, следующая — по курсору из next_cursor
+	var titles []string
+	base := "api/tasks?limit=2&sort=created&order=desc"
+	if Search {
+		base += "&search=постранично"
+	}
+	url := base
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 100)
+		status, header, body, err := requestHeader(url, nil, http.MethodGet, nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+		var m tasksPage
+		require.NoError(t, json.Unmarshal(body, &m))
+		for _, task := range m.Tasks {
+			titles = append(titles, task["title"])
+		}
+		// Заголовки дублируют поля ответа
+		assert.Equal(t, strconv.Itoa(m.Total), header.Get("X-Total-Count"))
+		assert.Equal(t, m.NextCursor, header.Get("X-Next-Cursor"))
+		if m.NextCursor == "" {
+			assert.Equal(t, m.Total, len(titles))
+			break
+		}
+		url = base + "&cursor=" + m.NextCursor
+	}
+	assert.Equal(t, []string{"Страница 3", "Страница 2", "Страница 1"}, titles[:3])
+
+	for _, param := range []string{"sort=priority", "order=up", "cursor=abc"} {
+		status, _, err := request("api/tasks?"+param, nil, http.MethodGet)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, status, param)
+	}
+}
+
+func TestTasksFilters(t *testing.T) {
+	if !Search {
+		return
+	}
+	// Задачи с датами вне диапазона, который видят остальные тесты
+	today := time.Now()
+	date := func(days int) string {
+		return today.AddDate(0, 0, days).Format(`20060102`)
+	}
+	filterTasks := func(params string) []string {
+		status, body, err := request("api/tasks?search=фильтр&limit=100&"+params, nil, http.MethodGet)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, status, params)
+		var m tasksPage
+		assert.NoError(t, json.Unmarshal(body, &m))
+		titles := make([]string, 0)
+		for _, task := range m.Tasks {
+			titles = append(titles, task["title"])
+		}
+		return titles
+	}
+
+	db := openDB(t)
+	defer db.Close()
+	// Просроченная задача добавляется напрямую: API переносит прошедшую дату на сегодня
+	_, err := db.Exec(`INSERT INTO scheduler (date, title, comment, repeat) VALUES (?, 'Старый фильтр', 'забыт', '')`, date(-3))
+	require.NoError(t, err)
+	addTask(t, task{date: date(0), title: "Фильтр на сегодня"})
+	addTask(t, task{date: date(40), title: "Фильтр в повторе", repeat: "d 5"})
+
+	assert.Equal(t, []string{"Старый фильтр"}, filterTasks("overdue=true&sort=date"))
+	assert.Equal(t, []string{"Фильтр на сегодня"}, filterTasks("today=true&sort=date"))
+	assert.Equal(t, []string{"Фильтр в повторе"}, filterTasks("repeating=true&sort=date"))
+	assert.Equal(t, []string{"Старый фильтр"}, filterTasks("has_comment=true&sort=date"))
+	assert.Equal(t, []string{"Фильтр на сегодня", "Фильтр в повторе"},
+		filterTasks("from="+date(0)+"&to="+date(40)+"&sort=date"))
+	assert.Equal(t, []string{"Старый фильтр", "Фильтр на сегодня"}, filterTasks("to=сегодня&sort=date"))
+	assert.Equal(t, []string{}, filterTasks("overdue=true&today=true"))
+
+	for _, param := range []string{"from=когда-нибудь", "overdue=может", "repeating=2"} {
+		status, _, err := request("api/tasks?"+param, nil, http.MethodGet)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, status, param)
+	}
+}
+
+func TestTasksQuery(t *testing.T) {
+	if !Search {
+		return
+	}
+	today := time.Now()
+	date := func(days int) string {
+		return today.AddDate(0, 0, days).Format(`20060102`)
+	}
+	queryTasks := func(params string) []string {
+		status, body, err := request("api/tasks?limit=100&"+params, nil, http.MethodGet)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, status, params)
+		var m tasksPage
+		assert.NoError(t, json.Unmarshal(body, &m))
+		titles := make([]string, 0)
+		for _, task := range m.Tasks {
+			titles = append(titles, task["title"])
+		}
+		return titles
+	}
+	search := func(s string) string {
+		return "search=" + url.QueryEscape(s)
+	}
+
+	addTask(t, task{date: date(50), title: "Запрос в банк", comment: "выписка"})
+	addTask(t, task{date: date(55), title: "Запрос в налоговую", repeat: "d 30"})
+	addTask(t, task{date: date(60), title: "Выписка для запроса"})
+
+	assert.Equal(t, []string{"Запрос в банк", "Выписка для запроса"}, queryTasks(search("запрос -repeat:d")))
+	assert.Equal(t, []string{"Запрос в банк", "Запрос в налоговую"}, queryTasks(search("title:запрос due<"+date(60))))
+	assert.Equal(t, []string{"Запрос в банк"}, queryTasks(search(`comment:выписка title:"запрос"`)))
+
+	// Ошибка в запросе возвращается с позицией
+	status, body, err := request("api/tasks?"+search("запрос due<завтра"), nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, status)
+	var resp map[string]any
+	assert.NoError(t, json.Unmarshal(body, &resp))
+	assert.Equal(t, "invalid_query", resp["code"])
+	assert.Equal(t, "search", resp["field"])
+	assert.Equal(t, float64(12), resp["position"])
+
+	// Сохранённые запросы
+	saveFilter := func(method, name, query string) int {
+		status, _, err := request("api/filters", map[string]any{"name": name, "query": query}, method)
+		assert.NoError(t, err)
+		return status
+	}
+	assert.Equal(t, http.StatusOK, saveFilter(http.MethodPost, "запросы", "title:запрос"))
+	assert.Equal(t, http.StatusConflict, saveFilter(http.MethodPost, "запросы", "запрос"))
+	assert.Equal(t, http.StatusBadRequest, saveFilter(http.MethodPost, "сломанный", "due<когда-нибудь"))
+	assert.Equal(t, http.StatusBadRequest, saveFilter(http.MethodPost, "", "запрос"))
+	assert.Equal(t, http.StatusNotFound, saveFilter(http.MethodPut, "другие", "запрос"))
+
+	assert.Equal(t, []string{"Запрос в банк", "Запрос в налоговую"},
+		queryTasks("filter="+url.QueryEscape("запросы")+"&"+search("due<"+date(60))))
+	assert.Equal(t, http.StatusOK, saveFilter(http.MethodPut, "запросы", "title:запрос repeat:d"))
+	assert.Equal(t, []string{"Запрос в налоговую"}, queryTasks("filter="+url.QueryEscape("запросы")))
+
+	status, body, err = request("api/filters", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	var list map[string][]map[string]string
+	assert.NoError(t, json.Unmarshal(body, &list))
+	assert.Contains(t, list["filters"], map[string]string{"name": "запросы", "query": "title:запрос repeat:d"})
+
+	status, body, err = request("api/filters?name="+url.QueryEscape("запросы"), nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{}`, string(body))
+
+	for path, field := range map[string]string{"api/filters?name=": "name", "api/tasks?filter=": "filter"} {
+		status, body, err = request(path+url.QueryEscape("запросы"), nil, http.MethodGet)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, status, path)
+		assert.NoError(t, json.Unmarshal(body, &resp))
+		assert.Equal(t, field, resp["field"], path)
+	}
+}